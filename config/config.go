@@ -1,6 +1,7 @@
 package config
 
 import (
+	"fmt"
 	"gopkg.in/yaml.v3"
 	"os"
 )
@@ -18,12 +19,92 @@ type Config struct {
 	} `yaml:"tree"`
 
 	Crawler struct {
-		QueueSize int `yaml:"queueSize"`
+		QueueSize        int    `yaml:"queueSize"`
+		Guess            bool   `yaml:"guess"`
+		RequestTimeoutMs int    `yaml:"requestTimeoutMs"`
+		SiteTimeoutMs    int    `yaml:"siteTimeoutMs"`
+		CacheDir         string `yaml:"cacheDir"`
+
+		Robots struct {
+			Enabled     bool     `yaml:"enabled"`
+			ExpiryMs    int      `yaml:"expiryMs"`
+			IgnoreHosts []string `yaml:"ignoreHosts"`
+		} `yaml:"robots"`
+
+		Retry struct {
+			Transient RetryPolicy `yaml:"transient"`
+			Throttled RetryPolicy `yaml:"throttled"`
+		} `yaml:"retry"`
+
+		Ftp struct {
+			Password      string `yaml:"password"`
+			Tls           string `yaml:"tls"`
+			IdleTimeoutMs int    `yaml:"idleTimeoutMs"`
+		} `yaml:"ftp"`
+
+		GitHub struct {
+			ApiUrl string `yaml:"apiUrl"`
+			Token  string `yaml:"token"`
+		} `yaml:"gitHub"`
+
+		Forges []struct {
+			Type   string `yaml:"type"`
+			Host   string `yaml:"host"`
+			ApiUrl string `yaml:"apiUrl"`
+			Token  string `yaml:"token"`
+		} `yaml:"forges"`
+
+		PyPI struct {
+			IndexUrl string `yaml:"indexUrl"`
+		} `yaml:"pyPI"`
+
+		Cpan struct {
+			ApiUrl string `yaml:"apiUrl"`
+		} `yaml:"cpan"`
+
+		Crates struct {
+			IndexUrl string `yaml:"indexUrl"`
+		} `yaml:"crates"`
+
+		GoProxy struct {
+			Url string `yaml:"url"`
+		} `yaml:"goProxy"`
+
+		Npm struct {
+			RegistryUrl string `yaml:"registryUrl"`
+		} `yaml:"npm"`
+
+		RubyGems struct {
+			Url string `yaml:"url"`
+		} `yaml:"rubyGems"`
+
+		Hackage struct {
+			Url string `yaml:"url"`
+		} `yaml:"hackage"`
 	} `yaml:"crawler"`
 
+	HostTracker struct {
+		MaxFailures   uint `yaml:"maxFailures"`
+		MinDowntimeMs int  `yaml:"minDowntimeMs"`
+		MaxDowntimeMs int  `yaml:"maxDowntimeMs"`
+	} `yaml:"hostTracker"`
+
 	CrawlLimiter struct {
 		MaxReqsCount    int `yaml:"maxReqsCount"`
 		MaxReqsWindowMs int `yaml:"maxReqsWindowMs"`
+		MaxInFlight     int `yaml:"maxInFlight"`
+		FlushIntervalMs int `yaml:"flushIntervalMs"`
+
+		Adaptive struct {
+			Enabled        bool `yaml:"enabled"`
+			SlowResponseMs int  `yaml:"slowResponseMs"`
+		} `yaml:"adaptive"`
+
+		Hosts []struct {
+			Pattern         string `yaml:"pattern"`
+			MaxReqsCount    int    `yaml:"maxReqsCount"`
+			MaxReqsWindowMs int    `yaml:"maxReqsWindowMs"`
+		} `yaml:"hosts"`
 	} `yaml:"crawlLimiter"`
 
 	Api struct {
@@ -31,6 +112,12 @@ type Config struct {
 	} `yaml:"api"`
 }
 
+type RetryPolicy struct {
+	MaxAttempts int `yaml:"maxAttempts"`
+	BaseDelayMs int `yaml:"baseDelayMs"`
+	MaxDelayMs  int `yaml:"maxDelayMs"`
+}
+
 func LoadConfig(configFile string) (*Config, error) {
 	data, err := os.ReadFile(configFile)
 
@@ -42,5 +129,29 @@ func LoadConfig(configFile string) (*Config, error) {
 
 	yaml.Unmarshal(data, &config)
 
+	err = config.validate()
+
+	if err != nil {
+		return nil, err
+	}
+
 	return &config, nil
 }
+
+func (config *Config) validate() error {
+	policies := []struct {
+		name   string
+		policy RetryPolicy
+	}{
+		{"transient", config.Crawler.Retry.Transient},
+		{"throttled", config.Crawler.Retry.Throttled},
+	}
+
+	for _, p := range policies {
+		if p.policy.MaxAttempts < 0 || p.policy.BaseDelayMs < 0 || p.policy.MaxDelayMs < 0 {
+			return fmt.Errorf("Invalid %s retry policy: values can't be negative", p.name)
+		}
+	}
+
+	return nil
+}
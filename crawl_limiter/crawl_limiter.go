@@ -3,39 +3,383 @@ package crawl_limiter
 import (
 	"context"
 	"golang.org/x/time/rate"
+	"log/slog"
 	"net/url"
+	"path"
+	"strings"
 	"sync"
 	"time"
+
+	"github.com/samott/portscout2/types"
 )
 
+// An adaptive host's rate never drops below this fraction of
+// its configured rate.
+const minRateFactor = 1.0 / 16
+
 type CrawlLimiter struct {
-	mu       sync.Mutex
-	maxReqs  int
-	window   time.Duration
-	limiters map[string]*rate.Limiter
+	mu        sync.Mutex
+	maxReqs   int
+	window    time.Duration
+	overrides []hostRate
+	hosts     map[string]*hostLimiter
+	inFlight  chan struct{}
+	adaptive  bool
+	slowAfter time.Duration
+	store     StateStore
+	saved     map[string]types.HostRateState
+}
+
+type StateStore interface {
+	GetHostRates() ([]types.HostRateState, error)
+	UpdateHostRates(states []types.HostRateState) error
+}
+
+type hostRate struct {
+	pattern string
+	maxReqs int
+	window  time.Duration
+}
+
+type hostLimiter struct {
+	limiter     *rate.Limiter
+	base        rate.Limit
+	paused      time.Time
+	lastRequest time.Time
+	dirty       bool
 }
 
 func NewCrawlLimiter(maxReqs int, window time.Duration) *CrawlLimiter {
 	return &CrawlLimiter{
-		maxReqs:  maxReqs,
-		window:   window,
-		limiters: make(map[string]*rate.Limiter),
+		maxReqs: maxReqs,
+		window:  window,
+		hosts:   make(map[string]*hostLimiter),
+		saved:   make(map[string]types.HostRateState),
 	}
 }
 
-func (c *CrawlLimiter) Wait(site *url.URL, ctx context.Context) {
+/**
+ * Loads the state saved by previous runs from the store, so that
+ * hosts accessed shortly before we started aren't hit again
+ * straight away and rates learned in adaptive mode carry over.
+ * State is written back to the store by Flush.
+ */
+func (c *CrawlLimiter) SetStore(store StateStore) error {
+	states, err := store.GetHostRates()
+
+	if err != nil {
+		return err
+	}
+
 	c.mu.Lock()
+	defer c.mu.Unlock()
 
-	host := site.Hostname()
+	c.store = store
 
-	l, ok := c.limiters[host]
+	for _, state := range states {
+		c.saved[strings.ToLower(state.Hostname)] = state
+	}
 
-	if !ok {
-		l = rate.NewLimiter(rate.Every(c.window), c.maxReqs)
-		c.limiters[host] = l
+	return nil
+}
+
+/**
+ * Writes the state of each host requested since the last flush to
+ * the store, if there is one.
+ */
+func (c *CrawlLimiter) Flush() error {
+	c.mu.Lock()
+
+	if c.store == nil {
+		c.mu.Unlock()
+		return nil
+	}
+
+	states := make([]types.HostRateState, 0)
+
+	for hostname, host := range c.hosts {
+		if !host.dirty {
+			continue
+		}
+
+		lastRequest := host.lastRequest
+		state := types.HostRateState{
+			Hostname:   hostname,
+			LastAccess: &lastRequest,
+		}
+
+		if limit := host.limiter.Limit(); limit < host.base {
+			state.LearnedRate = float64(limit)
+		}
+
+		states = append(states, state)
+		host.dirty = false
 	}
 
+	store := c.store
+
 	c.mu.Unlock()
 
-	l.Wait(ctx)
+	if err := store.UpdateHostRates(states); err != nil {
+		c.markDirty(states)
+		return err
+	}
+
+	return nil
+}
+
+// Flushes state every interval until the context is done.
+func (c *CrawlLimiter) RunFlush(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			if err := c.Flush(); err != nil {
+				slog.Error("Error saving crawl limiter state", "err", err)
+			}
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// So that state which couldn't be saved is tried again next time.
+func (c *CrawlLimiter) markDirty(states []types.HostRateState) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	for _, state := range states {
+		c.hosts[state.Hostname].dirty = true
+	}
+}
+
+/**
+ * Overrides the rate for hosts matching the pattern (using
+ * path.Match syntax, so e.g. "*.example.net" is permitted). The
+ * first matching override wins. Must be called before the limiter
+ * is used.
+ */
+func (c *CrawlLimiter) SetHostRate(pattern string, maxReqs int, window time.Duration) {
+	c.overrides = append(c.overrides, hostRate{
+		pattern: strings.ToLower(pattern),
+		maxReqs: maxReqs,
+		window:  window,
+	})
+}
+
+/**
+ * Caps the number of requests in flight at once across all hosts;
+ * zero means no cap. Must be called before the limiter is used.
+ */
+func (c *CrawlLimiter) SetMaxInFlight(maxInFlight int) {
+	if maxInFlight > 0 {
+		c.inFlight = make(chan struct{}, maxInFlight)
+	} else {
+		c.inFlight = nil
+	}
+}
+
+/**
+ * Enables adaptive mode, in which a host's rate is cut whenever it
+ * asks us to slow down (HTTP 429/503, FTP 421) or takes longer than
+ * slowAfter to respond, and creeps back up towards the configured
+ * rate with each prompt response. A zero slowAfter ignores response
+ * times.
+ */
+func (c *CrawlLimiter) SetAdaptive(enabled bool, slowAfter time.Duration) {
+	c.adaptive = enabled
+	c.slowAfter = slowAfter
+}
+
+/**
+ * Holds back all requests to the site's host for the given time,
+ * e.g. because it asked us to slow down. Overlapping back-offs
+ * don't shorten one another.
+ */
+func (c *CrawlLimiter) Backoff(site *url.URL, d time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	host := c.host(site)
+	until := time.Now().Add(d)
+
+	if until.After(host.paused) {
+		host.paused = until
+	}
+}
+
+/**
+ * Ensures requests to the site's host are at least d apart, e.g.
+ * as asked for by a Crawl-delay in robots.txt. This only ever
+ * slows a host down.
+ */
+func (c *CrawlLimiter) SetMinInterval(site *url.URL, d time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	host := c.host(site)
+	limit := rate.Every(d)
+
+	if limit >= host.base {
+		return
+	}
+
+	host.base = limit
+	host.limiter.SetBurst(1)
+
+	if host.limiter.Limit() > limit {
+		host.limiter.SetLimit(limit)
+	}
+}
+
+/**
+ * Blocks until a request to the site is allowed, or the context
+ * is done, in which case the context's error is returned. Each
+ * successful call must be followed by a call to Done once the
+ * request has completed.
+ */
+func (c *CrawlLimiter) Wait(site *url.URL, ctx context.Context) error {
+	c.mu.Lock()
+
+	host := c.host(site)
+	l := host.limiter
+	until := host.paused
+
+	c.mu.Unlock()
+
+	if delay := time.Until(until); delay > 0 {
+		timer := time.NewTimer(delay)
+		defer timer.Stop()
+
+		select {
+		case <-timer.C:
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+
+	if err := l.Wait(ctx); err != nil {
+		return err
+	}
+
+	c.mu.Lock()
+	host.lastRequest = time.Now()
+	host.dirty = true
+	c.mu.Unlock()
+
+	if c.inFlight == nil {
+		return nil
+	}
+
+	select {
+	case c.inFlight <- struct{}{}:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+/**
+ * Reports that a request allowed by Wait has completed, how long
+ * it took, and whether the server asked us to slow down.
+ */
+func (c *CrawlLimiter) Done(site *url.URL, latency time.Duration, throttled bool) {
+	if c.inFlight != nil {
+		<-c.inFlight
+	}
+
+	if !c.adaptive {
+		return
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	host := c.host(site)
+	current := host.limiter.Limit()
+	floor := host.base * minRateFactor
+
+	var limit rate.Limit
+
+	switch {
+	case throttled:
+		limit = max(current/2, floor)
+	case c.slowAfter > 0 && latency > c.slowAfter:
+		limit = max(current*3/4, floor)
+	default:
+		limit = min(current+host.base/10, host.base)
+	}
+
+	if limit < current {
+		slog.Debug("Slowing down host", "host", site.Hostname(), "rate", float64(limit), "throttled", throttled, "latency", latency)
+	}
+
+	host.limiter.SetLimit(limit)
+}
+
+// Must be called with the mutex held.
+func (c *CrawlLimiter) host(site *url.URL) *hostLimiter {
+	hostname := strings.ToLower(site.Hostname())
+
+	host, ok := c.hosts[hostname]
+
+	if ok {
+		return host
+	}
+
+	maxReqs, window := c.maxReqs, c.window
+
+	for _, override := range c.overrides {
+		if matched, _ := path.Match(override.pattern, hostname); matched {
+			maxReqs, window = override.maxReqs, override.window
+			break
+		}
+	}
+
+	base := rate.Every(window)
+
+	host = &hostLimiter{
+		limiter: rate.NewLimiter(base, maxReqs),
+		base:    base,
+	}
+
+	if saved, ok := c.saved[hostname]; ok {
+		c.restore(host, saved)
+	}
+
+	c.hosts[hostname] = host
+
+	return host
+}
+
+/**
+ * Applies state saved by a previous run to a new host limiter. A
+ * host accessed recently starts with no burst allowance and waits
+ * its turn as though the previous run had carried on.
+ */
+func (c *CrawlLimiter) restore(host *hostLimiter, saved types.HostRateState) {
+	if c.adaptive && saved.LearnedRate > 0 && rate.Limit(saved.LearnedRate) < host.base {
+		host.limiter.SetLimit(max(rate.Limit(saved.LearnedRate), host.base*minRateFactor))
+	}
+
+	if saved.LastAccess == nil {
+		return
+	}
+
+	host.lastRequest = *saved.LastAccess
+
+	limit := host.limiter.Limit()
+
+	if limit == rate.Inf || limit <= 0 {
+		return
+	}
+
+	next := saved.LastAccess.Add(time.Duration(float64(time.Second) / float64(limit)))
+
+	if time.Now().Before(next) {
+		host.limiter.AllowN(time.Now(), host.limiter.Burst())
+		host.paused = next
+	}
 }
@@ -0,0 +1,184 @@
+package crawl_limiter
+
+import (
+	"context"
+	"net/url"
+	"testing"
+	"time"
+
+	"golang.org/x/time/rate"
+
+	"github.com/samott/portscout2/types"
+)
+
+func TestHostRate(t *testing.T) {
+	c := NewCrawlLimiter(5, time.Second)
+
+	c.SetHostRate("*.example.net", 1, time.Minute)
+
+	site, _ := url.Parse("https://WWW.EXAMPLE.NET/pub/")
+	other, _ := url.Parse("https://www.example.org/pub/")
+
+	if limit := c.host(site).limiter.Limit(); limit != rate.Every(time.Minute) {
+		t.Fatal("Override not applied:", limit)
+	}
+
+	if limit := c.host(other).limiter.Limit(); limit != rate.Every(time.Second) {
+		t.Fatal("Default rate not applied:", limit)
+	}
+}
+
+func TestMaxInFlight(t *testing.T) {
+	c := NewCrawlLimiter(10, time.Millisecond)
+
+	c.SetMaxInFlight(1)
+
+	site, _ := url.Parse("https://www.example.net/pub/")
+
+	if err := c.Wait(site, context.Background()); err != nil {
+		t.Fatal("Wait failed:", err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+
+	if c.Wait(site, ctx) == nil {
+		t.Fatal("Second request allowed while first in flight")
+	}
+
+	c.Done(site, time.Millisecond, false)
+
+	if err := c.Wait(site, context.Background()); err != nil {
+		t.Fatal("Wait failed after Done:", err)
+	}
+}
+
+func TestAdaptive(t *testing.T) {
+	c := NewCrawlLimiter(1, time.Second)
+
+	c.SetAdaptive(true, time.Second)
+
+	site, _ := url.Parse("https://www.example.net/pub/")
+	base := rate.Every(time.Second)
+
+	c.Done(site, time.Millisecond, true)
+
+	if limit := c.host(site).limiter.Limit(); limit != base/2 {
+		t.Fatal("Rate not halved when throttled:", limit)
+	}
+
+	c.Done(site, 2*time.Second, false)
+
+	if limit := c.host(site).limiter.Limit(); limit != base*3/8 {
+		t.Fatal("Rate not cut for slow response:", limit)
+	}
+
+	for range 20 {
+		c.Done(site, time.Millisecond, true)
+	}
+
+	if limit := c.host(site).limiter.Limit(); limit != base*minRateFactor {
+		t.Fatal("Rate fell below floor:", limit)
+	}
+
+	for range 20 {
+		c.Done(site, time.Millisecond, false)
+	}
+
+	if limit := c.host(site).limiter.Limit(); limit != base {
+		t.Fatal("Rate not recovered:", limit)
+	}
+}
+
+type fakeStore struct {
+	states  []types.HostRateState
+	updated []types.HostRateState
+}
+
+func (s *fakeStore) GetHostRates() ([]types.HostRateState, error) {
+	return s.states, nil
+}
+
+func (s *fakeStore) UpdateHostRates(states []types.HostRateState) error {
+	s.updated = append(s.updated, states...)
+	return nil
+}
+
+func TestPersistence(t *testing.T) {
+	recent := time.Now()
+	old := time.Now().Add(-time.Hour)
+
+	store := &fakeStore{
+		states: []types.HostRateState{
+			{Hostname: "recent.example.net", LastAccess: &recent, LearnedRate: 0.25},
+			{Hostname: "old.example.net", LastAccess: &old},
+		},
+	}
+
+	c := NewCrawlLimiter(5, time.Second)
+
+	c.SetAdaptive(true, 0)
+
+	if err := c.SetStore(store); err != nil {
+		t.Fatal("SetStore failed:", err)
+	}
+
+	site, _ := url.Parse("https://recent.example.net/pub/")
+	host := c.host(site)
+
+	if host.limiter.Limit() != 0.25 {
+		t.Fatal("Learned rate not restored:", host.limiter.Limit())
+	}
+
+	if !host.paused.After(time.Now()) {
+		t.Fatal("Recently accessed host not held back")
+	}
+
+	oldSite, _ := url.Parse("ftp://old.example.net/pub/")
+
+	if c.host(oldSite).paused.After(time.Now()) {
+		t.Fatal("Host accessed long ago held back")
+	}
+
+	if err := c.Wait(oldSite, context.Background()); err != nil {
+		t.Fatal("Wait failed:", err)
+	}
+
+	c.Done(oldSite, time.Millisecond, true)
+
+	if err := c.Flush(); err != nil {
+		t.Fatal("Flush failed:", err)
+	}
+
+	if len(store.updated) != 1 || store.updated[0].Hostname != "old.example.net" {
+		t.Fatal("Incorrect hosts flushed:", store.updated)
+	}
+
+	if store.updated[0].LearnedRate != 0.5 || !store.updated[0].LastAccess.After(old) {
+		t.Fatal("Incorrect state flushed:", store.updated[0])
+	}
+
+	if err := c.Flush(); err != nil || len(store.updated) != 1 {
+		t.Fatal("Unchanged host flushed again")
+	}
+}
+
+func TestMinInterval(t *testing.T) {
+	c := NewCrawlLimiter(5, time.Second)
+
+	site, _ := url.Parse("https://www.example.net/pub/")
+
+	c.SetMinInterval(site, 10*time.Second)
+
+	host := c.host(site)
+
+	if host.limiter.Limit() != rate.Every(10*time.Second) || host.limiter.Burst() != 1 {
+		t.Fatal("Crawl delay not applied:", host.limiter.Limit(), host.limiter.Burst())
+	}
+
+	c.SetMinInterval(site, time.Millisecond)
+
+	if host.limiter.Limit() != rate.Every(10*time.Second) {
+		t.Fatal("Crawl delay sped host up:", host.limiter.Limit())
+	}
+}
@@ -0,0 +1,313 @@
+package crawl_planner
+
+import (
+	"cmp"
+	"hash/maphash"
+	"log/slog"
+	"maps"
+	"net/url"
+	"slices"
+	"strings"
+
+	"github.com/samott/portscout2/crawler"
+	"github.com/samott/portscout2/types"
+)
+
+type HostStatusInterface interface {
+	Status(hostname string) (types.HostStatus, bool)
+	IsAvailable(hostname string) bool
+}
+
+type Planner struct {
+	hosts HostStatusInterface
+	seed  maphash.Seed
+}
+
+// The host status source may be nil, in which case mirrors are
+// simply tried in random order.
+func NewPlanner(hosts HostStatusInterface) *Planner {
+	return &Planner{
+		hosts: hosts,
+		seed:  maphash.MakeSeed(),
+	}
+}
+
+/**
+ * Works out which sites should be crawled for a port, returning
+ * one job per site. Ignored ports yield no jobs.
+ *
+ * If the port's PORTSCOUT variable names an index site, it is
+ * crawled in addition to one job for each distfile group, with the
+ * group's master sites as mirrors of each other; the master sites
+ * of some ports are CDNs or download scripts with no listings, so
+ * the index site is often the only useful source.
+ *
+ * Ports fetched from GitHub, GitLab or Gitea/Forgejo instances are
+ * checked through the forge's API instead of their master sites,
+ * which can't be listed. Likewise, packages from registries such as
+ * CPAN and crates.io are checked through the registry's API rather
+ * than their master sites (see registries), as are Go modules.
+ */
+func (p *Planner) Plan(port types.PortInfo) []crawler.CrawlJob {
+	jobs := make([]crawler.CrawlJob, 0)
+
+	if port.Config.Ignore {
+		slog.Debug("Skipping ignored port", "port", port.Name)
+		return jobs
+	}
+
+	if port.Config.IndexSite != nil {
+		jobs = append(jobs, crawler.CrawlJob{
+			Port: port,
+			Site: expandSite(port, port.Config.IndexSite),
+			File: primaryFile(port),
+		})
+	}
+
+	if port.GitHub != nil {
+		jobs = append(jobs, crawler.CrawlJob{
+			Port: port,
+			Site: &url.URL{
+				Scheme: "https",
+				Host:   "github.com",
+				Path:   "/" + port.GitHub.Account + "/" + port.GitHub.Project,
+			},
+			File: primaryFile(port),
+		})
+	}
+
+	var forgeSite *url.URL
+
+	if port.Forge != nil {
+		site, err := url.Parse(port.Forge.Site)
+
+		if err != nil {
+			slog.Error("Invalid forge site", "port", port.Name, "err", err)
+		} else {
+			forgeSite = site.JoinPath(port.Forge.Account, port.Forge.Project)
+
+			jobs = append(jobs, crawler.CrawlJob{
+				Port: port,
+				Site: forgeSite,
+				File: primaryFile(port),
+			})
+		}
+	}
+
+	if port.GoModule != "" {
+		jobs = append(jobs, crawler.CrawlJob{
+			Port: port,
+			Site: &url.URL{
+				Scheme: "https",
+				Host:   "pkg.go.dev",
+				Path:   "/" + port.GoModule,
+			},
+			File: primaryFile(port),
+		})
+	}
+
+	planned := make(map[string]bool)
+
+	for group := range port.DistFiles {
+		if _, ok := port.MasterSites[group]; !ok {
+			// No sites for this distfile
+			continue
+		}
+
+		if port.GitHub != nil && allGitHub(port.MasterSites[group].Items) {
+			// Archives generated on demand; covered by the job above
+			continue
+		}
+
+		if forgeSite != nil && allOnHost(port.MasterSites[group].Items, forgeSite.Hostname()) {
+			continue
+		}
+
+		if port.GoModule != "" && allGoProxy(port.MasterSites[group].Items) {
+			continue
+		}
+
+		if reg, name := findRegistry(port, port.MasterSites[group].Items); reg != nil {
+			// One job covers every group from the registry
+			if !planned[reg.host] {
+				jobs = append(jobs, crawler.CrawlJob{
+					Port: port,
+					Site: &url.URL{
+						Scheme: "https",
+						Host:   reg.host,
+						Path:   reg.path + name,
+					},
+					File: port.DistFiles[group].Items[0],
+				})
+			}
+
+			planned[reg.host] = true
+			continue
+		}
+
+		sites := p.orderSites(port, port.MasterSites[group].Items)
+
+		if len(sites) == 0 {
+			continue
+		}
+
+		jobs = append(jobs, crawler.CrawlJob{
+			Port:    port,
+			Site:    sites[0],
+			Mirrors: sites[1:],
+			File:    port.DistFiles[group].Items[0],
+		})
+	}
+
+	return jobs
+}
+
+/**
+ * Orders a group's master sites for failover. Hosts which are known
+ * to be up come first, most recently successful first, followed by
+ * hosts we know nothing about and finally those marked as down
+ * which are due to be re-probed. Hosts which are down and not yet
+ * due are left out altogether.
+ *
+ * Sites are shuffled beforehand so that load is spread across
+ * mirrors we can't tell apart. The shuffle is the same for every
+ * port in a run, so ports sharing a set of mirrors pick the same
+ * one and the crawler only has to fetch its listing once.
+ */
+func (p *Planner) orderSites(port types.PortInfo, items []string) []*url.URL {
+	sites := make([]*url.URL, 0, len(items))
+
+	for _, item := range items {
+		site, err := url.Parse(item)
+
+		if err != nil {
+			slog.Error("Invalid master site", "port", port.Name, "err", err)
+			continue
+		}
+
+		if p.hosts != nil && !p.hosts.IsAvailable(site.Hostname()) {
+			slog.Debug("Skipping down host", "port", port.Name, "site", item)
+			continue
+		}
+
+		sites = append(sites, site)
+	}
+
+	slices.SortFunc(sites, func(a *url.URL, b *url.URL) int {
+		return cmp.Compare(maphash.String(p.seed, a.String()), maphash.String(p.seed, b.String()))
+	})
+
+	if p.hosts == nil {
+		return sites
+	}
+
+	slices.SortStableFunc(sites, func(a *url.URL, b *url.URL) int {
+		statusA, knownA := p.hosts.Status(a.Hostname())
+		statusB, knownB := p.hosts.Status(b.Hostname())
+
+		if rank(statusA, knownA) != rank(statusB, knownB) {
+			return rank(statusA, knownA) - rank(statusB, knownB)
+		}
+
+		if statusA.AccessedAt == nil || statusB.AccessedAt == nil {
+			return 0
+		}
+
+		return statusB.AccessedAt.Compare(*statusA.AccessedAt)
+	})
+
+	return sites
+}
+
+func rank(status types.HostStatus, known bool) int {
+	switch {
+	case !known:
+		return 1
+	case status.IsDown:
+		return 2
+	case status.AccessedAt == nil:
+		return 1
+	default:
+		return 0
+	}
+}
+
+func allGitHub(sites []string) bool {
+	for _, site := range sites {
+		u, err := url.Parse(site)
+
+		if err != nil {
+			return false
+		}
+
+		host := u.Hostname()
+
+		if host != "github.com" && host != "codeload.github.com" {
+			return false
+		}
+	}
+
+	return true
+}
+
+func allOnHost(sites []string, host string) bool {
+	for _, site := range sites {
+		u, err := url.Parse(site)
+
+		if err != nil || !strings.EqualFold(u.Hostname(), host) {
+			return false
+		}
+	}
+
+	return true
+}
+
+func allGoProxy(sites []string) bool {
+	for _, site := range sites {
+		u, err := url.Parse(site)
+
+		if err != nil || (u.Hostname() != "proxy.golang.org" && !strings.Contains(u.Path, "/@v/")) {
+			return false
+		}
+	}
+
+	return true
+}
+
+/**
+ * Substitutes placeholders in an index site URL with values from
+ * the port, so that e.g. "https://example.net/foo/%VERSION%/"
+ * points at the directory for the current release. The original
+ * URL is left untouched.
+ */
+func expandSite(port types.PortInfo, site *url.URL) *url.URL {
+	replacer := strings.NewReplacer(
+		"%VERSION%", port.DistVersion,
+		"%DISTNAME%", port.DistName,
+	)
+
+	expanded := *site
+	expanded.Path = replacer.Replace(site.Path)
+	expanded.RawPath = ""
+
+	return &expanded
+}
+
+/**
+ * Returns the distfile most representative of the port: the first
+ * one in the default group if there is one, otherwise the first
+ * in the alphabetically first group.
+ */
+func primaryFile(port types.PortInfo) string {
+	if list, ok := port.DistFiles[""]; ok && len(list.Items) > 0 {
+		return list.Items[0]
+	}
+
+	for _, group := range slices.Sorted(maps.Keys(port.DistFiles)) {
+		if len(port.DistFiles[group].Items) > 0 {
+			return port.DistFiles[group].Items[0]
+		}
+	}
+
+	return ""
+}
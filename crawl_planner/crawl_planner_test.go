@@ -0,0 +1,284 @@
+package crawl_planner
+
+import (
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/samott/portscout2/types"
+)
+
+func TestPlan(t *testing.T) {
+	planner := NewPlanner(nil)
+
+	port := types.PortInfo{
+		Name:        types.PortName{Category: "cat", Name: "foo"},
+		DistName:    "foo-1.2",
+		DistVersion: "1.2",
+		DistFiles:   types.UnmarshalTaggedLists("foo-1.2.tar.gz foo-docs-1.2.zip:docs"),
+		MasterSites: types.UnmarshalTaggedLists("https://cdn.example.net/foo/ https://www.example.net/docs/:docs"),
+		Config: types.PortConfig{
+			LimitWhich: -1,
+		},
+	}
+
+	jobs := planner.Plan(port)
+
+	if len(jobs) != 2 {
+		t.Fatal("Incorrect job count:", len(jobs))
+	}
+
+	index, _ := url.Parse("https://www.example.net/foo/releases/%25VERSION%25/")
+	port.Config.IndexSite = index
+
+	jobs = planner.Plan(port)
+
+	if len(jobs) != 3 {
+		t.Fatal("Incorrect job count with index site:", len(jobs))
+	}
+
+	if jobs[0].Site.String() != "https://www.example.net/foo/releases/1.2/" {
+		t.Fatal("Incorrect index site:", jobs[0].Site)
+	}
+
+	if jobs[0].File != "foo-1.2.tar.gz" {
+		t.Fatal("Incorrect index site file:", jobs[0].File)
+	}
+
+	if port.Config.IndexSite.Path != "/foo/releases/%VERSION%/" {
+		t.Fatal("Index site modified")
+	}
+
+	port.Config.Ignore = true
+
+	if len(planner.Plan(port)) != 0 {
+		t.Fatal("Ignored port planned")
+	}
+}
+
+func TestPlanGitHub(t *testing.T) {
+	planner := NewPlanner(nil)
+
+	port := types.PortInfo{
+		Name:        types.PortName{Category: "cat", Name: "bar"},
+		DistVersion: "1.0",
+		DistFiles:   types.UnmarshalTaggedLists("acct-bar-v1.0_GH0.tar.gz"),
+		MasterSites: types.UnmarshalTaggedLists("https://codeload.github.com/acct/bar/tar.gz/v1.0?dummy=/"),
+		GitHub: &types.GitHubInfo{
+			Account: "acct",
+			Project: "bar",
+			TagName: "v1.0",
+		},
+		Config: types.PortConfig{
+			LimitWhich: -1,
+		},
+	}
+
+	jobs := planner.Plan(port)
+
+	if len(jobs) != 1 {
+		t.Fatal("Incorrect job count:", len(jobs))
+	}
+
+	if jobs[0].Site.String() != "https://github.com/acct/bar" {
+		t.Fatal("Incorrect GitHub site:", jobs[0].Site)
+	}
+}
+
+func TestPlanForge(t *testing.T) {
+	planner := NewPlanner(nil)
+
+	port := types.PortInfo{
+		Name:        types.PortName{Category: "cat", Name: "baz"},
+		DistVersion: "1.0",
+		DistFiles:   types.UnmarshalTaggedLists("v1.0.tar.gz baz-data-1.0.zip:data"),
+		MasterSites: types.UnmarshalTaggedLists("https://codeberg.org/acct/baz/archive/ https://www.example.net/data/:data"),
+		Forge: &types.ForgeInfo{
+			Type:    types.ForgeGitea,
+			Site:    "https://codeberg.org",
+			Account: "acct",
+			Project: "baz",
+			TagName: "v1.0",
+		},
+		Config: types.PortConfig{
+			LimitWhich: -1,
+		},
+	}
+
+	jobs := planner.Plan(port)
+
+	if len(jobs) != 2 {
+		t.Fatal("Incorrect job count:", len(jobs))
+	}
+
+	if jobs[0].Site.String() != "https://codeberg.org/acct/baz" {
+		t.Fatal("Incorrect forge site:", jobs[0].Site)
+	}
+
+	if jobs[1].Site.String() != "https://www.example.net/data/" {
+		t.Fatal("Incorrect data site:", jobs[1].Site)
+	}
+}
+
+func TestPlanCpan(t *testing.T) {
+	planner := NewPlanner(nil)
+
+	port := types.PortInfo{
+		Name:        types.PortName{Category: "cat", Name: "p5-Foo-Bar"},
+		DistName:    "Foo-Bar-v1.2.3",
+		DistVersion: "1.2.3",
+		DistFiles:   types.UnmarshalTaggedLists("Foo-Bar-v1.2.3.tar.gz"),
+		MasterSites: types.UnmarshalTaggedLists("https://cpan.metacpan.org/modules/by-module/Foo/ ftp://ftp.cpan.org/pub/CPAN/authors/id/A/AU/AUTHOR/"),
+		Config: types.PortConfig{
+			LimitWhich: -1,
+		},
+	}
+
+	jobs := planner.Plan(port)
+
+	if len(jobs) != 1 {
+		t.Fatal("Incorrect job count:", len(jobs))
+	}
+
+	if jobs[0].Site.String() != "https://metacpan.org/dist/Foo-Bar" {
+		t.Fatal("Incorrect CPAN site:", jobs[0].Site)
+	}
+
+	port.DistName = "Foo-Bar-2.0"
+
+	if dist := distBaseName(port, nil); dist != "Foo-Bar" {
+		t.Fatal("Incorrect distribution:", dist)
+	}
+}
+
+func TestPlanRegistries(t *testing.T) {
+	planner := NewPlanner(nil)
+
+	port := types.PortInfo{
+		Name:        types.PortName{Category: "cat", Name: "ripgrep"},
+		DistName:    "ripgrep-14.1.0",
+		DistVersion: "14.1.0",
+		DistFiles:   types.UnmarshalTaggedLists("ripgrep-14.1.0.crate"),
+		MasterSites: types.UnmarshalTaggedLists("https://crates.io/api/v1/crates/ripgrep/14.1.0/download?dummy=/"),
+		Config: types.PortConfig{
+			LimitWhich: -1,
+		},
+	}
+
+	jobs := planner.Plan(port)
+
+	if len(jobs) != 1 || jobs[0].Site.String() != "https://crates.io/crates/ripgrep" {
+		t.Fatal("Incorrect crate jobs:", jobs)
+	}
+
+	port = types.PortInfo{
+		Name:        types.PortName{Category: "cat", Name: "tool"},
+		DistVersion: "1.2.0",
+		DistFiles:   types.UnmarshalTaggedLists("v1.2.0.zip v1.2.0.mod"),
+		MasterSites: types.UnmarshalTaggedLists("https://proxy.golang.org/github.com/acct/tool/@v/"),
+		GoModule:    "github.com/acct/tool",
+		Config: types.PortConfig{
+			LimitWhich: -1,
+		},
+	}
+
+	jobs = planner.Plan(port)
+
+	if len(jobs) != 1 || jobs[0].Site.String() != "https://pkg.go.dev/github.com/acct/tool" {
+		t.Fatal("Incorrect Go module jobs:", jobs)
+	}
+
+	tests := []struct {
+		name     string
+		distName string
+		sites    string
+		expected string
+	}{
+		{"npm-pkg", "pkg-1.0.0", "https://registry.npmjs.org/@scope/pkg/-/", "https://www.npmjs.com/package/@scope/pkg"},
+		{"rubygem-rake", "rake-13.2.0", "https://rubygems.org/downloads/", "https://rubygems.org/gems/rake"},
+		{"hs-pandoc", "pandoc-3.1.2", "https://hackage.haskell.org/package/pandoc-3.1.2/", "https://hackage.haskell.org/package/pandoc"},
+		{"hs-other", "other-0.1", "https://www.example.net/other/", "https://hackage.haskell.org/package/other"},
+	}
+
+	for _, tc := range tests {
+		port = types.PortInfo{
+			Name:        types.PortName{Category: "cat", Name: tc.name},
+			DistName:    tc.distName,
+			DistVersion: strings.TrimPrefix(tc.distName, strings.SplitN(tc.distName, "-", 2)[0]+"-"),
+			DistFiles:   types.UnmarshalTaggedLists(tc.distName + ".tgz"),
+			MasterSites: types.UnmarshalTaggedLists(tc.sites),
+			Config: types.PortConfig{
+				LimitWhich: -1,
+			},
+		}
+
+		jobs = planner.Plan(port)
+
+		if len(jobs) != 1 || jobs[0].Site.String() != tc.expected {
+			t.Fatal("Incorrect jobs for", tc.name, jobs)
+		}
+	}
+}
+
+type fakeHosts map[string]types.HostStatus
+
+func (h fakeHosts) Status(hostname string) (types.HostStatus, bool) {
+	status, ok := h[hostname]
+	return status, ok
+}
+
+func (h fakeHosts) IsAvailable(hostname string) bool {
+	status, ok := h[hostname]
+	return !ok || !status.IsDown || !time.Now().Before(*status.DownUntil)
+}
+
+func TestPlanMirrorOrder(t *testing.T) {
+	older := time.Now().Add(-time.Hour)
+	newer := time.Now()
+	later := time.Now().Add(time.Hour)
+
+	planner := NewPlanner(fakeHosts{
+		"down.example.net":  {Hostname: "down.example.net", AccessedAt: &newer, IsDown: true, DownUntil: &older},
+		"dead.example.net":  {Hostname: "dead.example.net", IsDown: true, DownUntil: &later},
+		"old.example.net":   {Hostname: "old.example.net", AccessedAt: &older},
+		"fresh.example.net": {Hostname: "fresh.example.net", AccessedAt: &newer},
+	})
+
+	port := types.PortInfo{
+		Name:        types.PortName{Category: "cat", Name: "foo"},
+		DistVersion: "1.2",
+		DistFiles:   types.UnmarshalTaggedLists("foo-1.2.tar.gz"),
+		MasterSites: types.UnmarshalTaggedLists("https://down.example.net/ https://dead.example.net/ https://unknown.example.net/ https://old.example.net/ https://fresh.example.net/"),
+		Config: types.PortConfig{
+			LimitWhich: -1,
+		},
+	}
+
+	jobs := planner.Plan(port)
+
+	if len(jobs) != 1 {
+		t.Fatal("Incorrect job count:", len(jobs))
+	}
+
+	expected := []string{
+		"fresh.example.net",
+		"old.example.net",
+		"unknown.example.net",
+		"down.example.net",
+	}
+
+	if jobs[0].Site.Hostname() != expected[0] {
+		t.Fatal("Incorrect first site:", jobs[0].Site)
+	}
+
+	if len(jobs[0].Mirrors) != len(expected)-1 {
+		t.Fatal("Incorrect mirror count:", len(jobs[0].Mirrors))
+	}
+
+	for i, mirror := range jobs[0].Mirrors {
+		if mirror.Hostname() != expected[i+1] {
+			t.Fatal("Incorrect mirror order:", jobs[0].Mirrors)
+		}
+	}
+}
@@ -0,0 +1,179 @@
+package crawl_planner
+
+import (
+	"net/url"
+	"regexp"
+	"strings"
+
+	"github.com/samott/portscout2/types"
+)
+
+/**
+ * A package registry with an API through which new versions are
+ * found. Jobs for its packages have the site https://<host><path><name>,
+ * where host is that on which the registry's handler is registered.
+ * A distfile group belongs to the registry if match accepts the
+ * port and the group's master sites; the package name is then
+ * worked out by name, and if it's empty the group is crawled as
+ * usual.
+ */
+type registry struct {
+	host  string
+	path  string
+	match func(port types.PortInfo, sites []*url.URL) bool
+	name  func(port types.PortInfo, sites []*url.URL) string
+}
+
+var registries = []registry{
+	{
+		host:  "metacpan.org",
+		path:  "/dist/",
+		match: allSites(isCpanSite),
+		name:  distBaseName,
+	},
+	{
+		host:  "crates.io",
+		path:  "/crates/",
+		match: allSites(isCratesSite),
+		name:  distBaseName,
+	},
+	{
+		host:  "www.npmjs.com",
+		path:  "/package/",
+		match: anyOf(namePrefix("npm-"), allSites(isNpmSite)),
+		name:  npmPackageName,
+	},
+	{
+		host:  "rubygems.org",
+		path:  "/gems/",
+		match: anyOf(namePrefix("rubygem-"), allSites(onHost("rubygems.org"))),
+		name:  distBaseName,
+	},
+	{
+		host:  "hackage.haskell.org",
+		path:  "/package/",
+		match: anyOf(namePrefix("hs-"), allSites(onHost("hackage.haskell.org"))),
+		name:  distBaseName,
+	},
+}
+
+// Returns the registry to which a distfile group belongs, if any,
+// along with the name of the port's package.
+func findRegistry(port types.PortInfo, items []string) (*registry, string) {
+	sites := make([]*url.URL, 0, len(items))
+
+	for _, item := range items {
+		site, err := url.Parse(item)
+
+		if err != nil {
+			return nil, ""
+		}
+
+		sites = append(sites, site)
+	}
+
+	if len(sites) == 0 {
+		return nil, ""
+	}
+
+	for i := range registries {
+		if !registries[i].match(port, sites) {
+			continue
+		}
+
+		if name := registries[i].name(port, sites); name != "" {
+			return &registries[i], name
+		}
+	}
+
+	return nil, ""
+}
+
+func allSites(match func(site *url.URL) bool) func(port types.PortInfo, sites []*url.URL) bool {
+	return func(port types.PortInfo, sites []*url.URL) bool {
+		for _, site := range sites {
+			if !match(site) {
+				return false
+			}
+		}
+
+		return true
+	}
+}
+
+func anyOf(matches ...func(port types.PortInfo, sites []*url.URL) bool) func(port types.PortInfo, sites []*url.URL) bool {
+	return func(port types.PortInfo, sites []*url.URL) bool {
+		for _, match := range matches {
+			if match(port, sites) {
+				return true
+			}
+		}
+
+		return false
+	}
+}
+
+// Ports named after the registry, e.g. "rubygem-rake".
+func namePrefix(prefix string) func(port types.PortInfo, sites []*url.URL) bool {
+	return func(port types.PortInfo, sites []*url.URL) bool {
+		return strings.HasPrefix(port.Name.Name, prefix)
+	}
+}
+
+func onHost(host string) func(site *url.URL) bool {
+	return func(site *url.URL) bool {
+		return strings.EqualFold(site.Hostname(), host)
+	}
+}
+
+// MASTER_SITES=CPAN and CPAN:AUTHOR, on any mirror.
+func isCpanSite(site *url.URL) bool {
+	return strings.Contains(site.Path, "/modules/by-module/") || strings.Contains(site.Path, "/authors/id/")
+}
+
+// MASTER_SITES=CRATESIO.
+func isCratesSite(site *url.URL) bool {
+	host := site.Hostname()
+
+	return host == "crates.io" || host == "static.crates.io"
+}
+
+// Tarballs in the npm registry, e.g. https://registry.npmjs.org/@scope/name/-/.
+func isNpmSite(site *url.URL) bool {
+	return site.Hostname() == "registry.npmjs.org" && strings.Contains(site.Path, "/-/")
+}
+
+/**
+ * Takes the npm package name from the registry URL, since the
+ * names of scoped packages ("@scope/name") don't appear in DISTNAME.
+ */
+func npmPackageName(port types.PortInfo, sites []*url.URL) string {
+	for _, site := range sites {
+		if !isNpmSite(site) {
+			continue
+		}
+
+		name, _, _ := strings.Cut(strings.TrimPrefix(site.Path, "/"), "/-/")
+
+		return name
+	}
+
+	return distBaseName(port, sites)
+}
+
+var distVersionRegex = regexp.MustCompile(`-v?[0-9][0-9._]*$`)
+
+/**
+ * Works out a registry package name from the port's DISTNAME,
+ * which is the package name followed by its version ("Foo-Bar-1.23"),
+ * and which unlike the port's name has no prefix such as "p5-".
+ */
+func distBaseName(port types.PortInfo, sites []*url.URL) string {
+	for _, suffix := range []string{"-" + port.DistVersion, "-v" + port.DistVersion} {
+		if name, ok := strings.CutSuffix(port.DistName, suffix); ok && port.DistVersion != "" {
+			return name
+		}
+	}
+
+	return distVersionRegex.ReplaceAllString(port.DistName, "")
+}
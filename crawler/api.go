@@ -0,0 +1,135 @@
+package crawler
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"regexp"
+)
+
+var linkNextRegex = regexp.MustCompile(`<([^>]+)>\s*;\s*rel="?next"?`)
+
+/**
+ * Parses the configured base URL of an upstream API, falling back
+ * to the given default if none is configured. Name is used in the
+ * error, e.g. "npm registry".
+ */
+func parseApiUrl(raw string, def string, name string) (*url.URL, error) {
+	if raw == "" {
+		raw = def
+	}
+
+	u, err := url.Parse(raw)
+
+	if err != nil {
+		return nil, fmt.Errorf("Invalid %s URL: %w", name, err)
+	}
+
+	return u, nil
+}
+
+/**
+ * Fetches a JSON document from an upstream API and decodes it into
+ * v, returning the response headers so that callers can deal with
+ * pagination. Requests are subject to the crawler's rate limiter
+ * just like ordinary site crawls.
+ */
+func (c *Crawler) fetchJson(ctx context.Context, u *url.URL, header http.Header, v any) (http.Header, error) {
+	if header.Get("Accept") == "" {
+		header = header.Clone()
+
+		if header == nil {
+			header = make(http.Header)
+		}
+
+		header.Set("Accept", "application/json")
+	}
+
+	return c.fetchApi(ctx, u, header, func(r io.Reader) error {
+		return json.NewDecoder(r).Decode(v)
+	})
+}
+
+/**
+ * Fetches a document from an upstream API and hands its body to
+ * read, for APIs whose responses aren't a single JSON value.
+ */
+func (c *Crawler) fetchApi(ctx context.Context, u *url.URL, header http.Header, read func(r io.Reader) error) (http.Header, error) {
+	req, err := http.NewRequestWithContext(ctx, "GET", u.String(), nil)
+
+	if err != nil {
+		return nil, fmt.Errorf("Error creating request: %w", err)
+	}
+
+	for key, vals := range header {
+		for _, val := range vals {
+			req.Header.Add(key, val)
+		}
+	}
+
+	req.Header.Set("User-Agent", "portscout/2")
+
+	resp, err := c.httpClient().Do(req)
+
+	if err != nil {
+		return nil, fmt.Errorf("Error making request: %w", err)
+	}
+
+	defer resp.Body.Close()
+
+	if resp.StatusCode > 299 {
+		return nil, newStatusError(resp)
+	}
+
+	err = read(resp.Body)
+
+	if err != nil {
+		return nil, fmt.Errorf("Error decoding response: %w", err)
+	}
+
+	return resp.Header, nil
+}
+
+/**
+ * Fetches every page of a paginated JSON collection, following the
+ * Link headers, up to maxPages pages.
+ */
+func fetchAllPages[T any](ctx context.Context, c *Crawler, u *url.URL, header http.Header, maxPages int) ([]T, error) {
+	items := make([]T, 0)
+
+	for page := 0; u != nil && page < maxPages; page++ {
+		var pageItems []T
+
+		respHeader, err := c.fetchJson(ctx, u, header, &pageItems)
+
+		if err != nil {
+			return nil, err
+		}
+
+		items = append(items, pageItems...)
+
+		u = nextLink(respHeader, u)
+	}
+
+	return items, nil
+}
+
+// Returns the rel="next" URL from an RFC 8288 Link header, if any.
+func nextLink(header http.Header, base *url.URL) *url.URL {
+	for _, link := range header.Values("Link") {
+		matches := linkNextRegex.FindStringSubmatch(link)
+
+		if matches == nil {
+			continue
+		}
+
+		if u, err := base.Parse(matches[1]); err == nil {
+			return u
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,308 @@
+package crawler
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"math"
+	"mime"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/samott/portscout2/types"
+)
+
+// The software which generated a directory listing.
+type listingFlavour int
+
+const (
+	// Some other HTML page with links
+	flavourGeneric listingFlavour = iota
+	// Apache mod_autoindex, as a table or preformatted
+	flavourApache
+	// nginx autoindex
+	flavourNginx
+	// lighttpd mod_dirlisting
+	flavourLighttpd
+	// nginx autoindex with autoindex_format json
+	flavourNginxJson
+	// Caddy file_server browse, asked for JSON
+	flavourCaddyJson
+)
+
+func (f listingFlavour) String() string {
+	switch f {
+	case flavourApache:
+		return "apache"
+	case flavourNginx:
+		return "nginx"
+	case flavourLighttpd:
+		return "lighttpd"
+	case flavourNginxJson:
+		return "nginx-json"
+	case flavourCaddyJson:
+		return "caddy-json"
+	default:
+		return "generic"
+	}
+}
+
+/**
+ * The date formats used for modification times in each flavour of
+ * HTML listing. Times are taken to be UTC, since listings don't say.
+ */
+func (f listingFlavour) dateLayouts() []string {
+	switch f {
+	case flavourApache:
+		return []string{"2006-01-02 15:04", "02-Jan-2006 15:04"}
+	case flavourNginx:
+		return []string{"02-Jan-2006 15:04"}
+	case flavourLighttpd:
+		return []string{"2006-Jan-02 15:04:05"}
+	default:
+		return []string{
+			"2006-01-02 15:04",
+			"2006-01-02 15:04:05",
+			"02-Jan-2006 15:04",
+			"2006-Jan-02 15:04:05",
+		}
+	}
+}
+
+/**
+ * Parses a directory listing according to its content type: JSON
+ * (from nginx or Caddy) or HTML (anything else).
+ */
+func parseListing(page *url.URL, contentType string, r io.Reader) (listing, listingFlavour, error) {
+	mediaType, _, _ := mime.ParseMediaType(contentType)
+
+	if mediaType == "application/json" {
+		return parseJsonListing(page, r)
+	}
+
+	return parseHtmlLinks(page, r)
+}
+
+// Telltale signs of the various HTML listing flavours.
+type flavourHints struct {
+	sortLinks bool
+	signature string
+	classes   bool
+	pre       bool
+	indexOf   bool
+	inAddress bool
+	inTitle   bool
+}
+
+func (h *flavourHints) startTag(tag string, attrs map[string]string) {
+	switch tag {
+	case "a":
+		// Apache's column sorting links
+		if strings.HasPrefix(attrs["href"], "?C=") {
+			h.sortLinks = true
+		}
+	case "td", "div":
+		// lighttpd's table cells and wrapper
+		if attrs["class"] == "n" || attrs["class"] == "list" {
+			h.classes = true
+		}
+	case "pre":
+		h.pre = true
+	case "address":
+		h.inAddress = true
+	case "title":
+		h.inTitle = true
+	}
+}
+
+func (h *flavourHints) endTag(tag string) {
+	switch tag {
+	case "address":
+		h.inAddress = false
+	case "title":
+		h.inTitle = false
+	}
+}
+
+func (h *flavourHints) text(text string) {
+	if h.inAddress {
+		h.signature += text
+	}
+
+	if h.inTitle && strings.HasPrefix(strings.TrimSpace(text), "Index of") {
+		h.indexOf = true
+	}
+}
+
+func (h *flavourHints) flavour() listingFlavour {
+	switch {
+	case h.sortLinks || strings.HasPrefix(strings.TrimSpace(h.signature), "Apache"):
+		return flavourApache
+	case h.classes:
+		return flavourLighttpd
+	case h.pre && h.indexOf:
+		return flavourNginx
+	default:
+		return flavourGeneric
+	}
+}
+
+var listingSize = regexp.MustCompile(`^(\d+(?:\.\d+)?)([KMGT]?)$`)
+
+/**
+ * Reads a modification time and size from the text following a
+ * link in an HTML listing, e.g. "2024-01-31 12:00  1.2M". All the
+ * flavours give the time first, then the size, either in bytes or
+ * abbreviated with binary units. Returns false if neither is found.
+ */
+func parseFileInfo(text string, layouts []string) (types.FileInfo, bool) {
+	info := types.FileInfo{
+		Size: -1,
+	}
+
+	fields := strings.Fields(text)
+	rest := fields
+
+	for i := 0; i+1 < len(fields) && info.ModTime.IsZero(); i++ {
+		for _, layout := range layouts {
+			t, err := time.Parse(layout, fields[i]+" "+fields[i+1])
+
+			if err == nil {
+				info.ModTime = t
+				rest = fields[i+2:]
+				break
+			}
+		}
+	}
+
+	if len(rest) > 0 {
+		info.Size = parseListingSize(rest[0])
+	}
+
+	return info, info.Size >= 0 || !info.ModTime.IsZero()
+}
+
+// Returns the size in bytes, or -1 if it isn't one (e.g. "-").
+func parseListingSize(value string) int64 {
+	match := listingSize.FindStringSubmatch(strings.ToUpper(value))
+
+	if match == nil {
+		return -1
+	}
+
+	if match[2] == "" {
+		size, err := strconv.ParseInt(match[1], 10, 64)
+
+		if err != nil {
+			return -1
+		}
+
+		return size
+	}
+
+	size, err := strconv.ParseFloat(match[1], 64)
+
+	if err != nil {
+		return -1
+	}
+
+	exp := float64(strings.Index("KMGT", match[2]) + 1)
+
+	return int64(math.Round(size * math.Pow(1024, exp)))
+}
+
+/**
+ * An entry in a JSON listing. nginx gives name, type, mtime (an
+ * HTTP date) and size; Caddy gives name, url, is_dir, mod_time
+ * (RFC 3339) and size.
+ */
+type jsonListingEntry struct {
+	Name    string    `json:"name"`
+	Type    string    `json:"type"`
+	Mtime   string    `json:"mtime"`
+	Url     string    `json:"url"`
+	IsDir   bool      `json:"is_dir"`
+	ModTime time.Time `json:"mod_time"`
+	Size    *int64    `json:"size"`
+}
+
+func parseJsonListing(page *url.URL, r io.Reader) (listing, listingFlavour, error) {
+	var entries []jsonListingEntry
+
+	err := json.NewDecoder(r).Decode(&entries)
+
+	if err != nil {
+		return listing{}, flavourGeneric, fmt.Errorf("Invalid JSON listing: %w", err)
+	}
+
+	dir := listing{
+		files: make([]*url.URL, 0),
+		info:  make(map[string]types.FileInfo),
+		dirs:  make([]*url.URL, 0),
+	}
+
+	flavour := flavourCaddyJson
+
+	if len(entries) > 0 && entries[0].Type != "" {
+		flavour = flavourNginxJson
+	}
+
+	for _, entry := range entries {
+		if entry.Name == "" || entry.Type == "other" {
+			continue
+		}
+
+		isDir := entry.IsDir || entry.Type == "directory"
+		href := entry.Url
+
+		if href == "" {
+			name := strings.TrimSuffix(entry.Name, "/")
+
+			if isDir {
+				name += "/"
+			}
+
+			// Escapes the name, and guards against colons
+			href = (&url.URL{Path: name}).String()
+		}
+
+		link, err := page.Parse(href)
+
+		if err != nil {
+			continue
+		}
+
+		if isDir {
+			if isSubdirLink(page, link) {
+				dir.dirs = append(dir.dirs, link)
+			}
+			continue
+		}
+
+		if !isFileLink(page, link) {
+			continue
+		}
+
+		info := types.FileInfo{
+			Size:    -1,
+			ModTime: entry.ModTime.UTC(),
+		}
+
+		if entry.Size != nil {
+			info.Size = *entry.Size
+		}
+
+		if t, err := http.ParseTime(entry.Mtime); err == nil {
+			info.ModTime = t.UTC()
+		}
+
+		dir.files = append(dir.files, link)
+		dir.info[link.String()] = info
+	}
+
+	return dir, flavour, nil
+}
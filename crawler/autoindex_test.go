@@ -0,0 +1,166 @@
+package crawler
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+var updateGolden = flag.Bool("update", false, "Rewrite golden files")
+
+/**
+ * Parses each sample listing in testdata/listings and compares the
+ * result with the accompanying .golden file. Run with -update to
+ * regenerate the golden files after a deliberate change.
+ */
+func TestParseListingGolden(t *testing.T) {
+	samples, err := filepath.Glob("testdata/listings/*.*")
+
+	if err != nil {
+		t.Fatal("Glob failed:", err)
+	}
+
+	page, _ := url.Parse("http://www.example.net/pub/foo/")
+
+	for _, sample := range samples {
+		if strings.HasSuffix(sample, ".golden") {
+			continue
+		}
+
+		t.Run(filepath.Base(sample), func(t *testing.T) {
+			f, err := os.Open(sample)
+
+			if err != nil {
+				t.Fatal("Unable to open sample:", err)
+			}
+
+			defer f.Close()
+
+			contentType := "text/html; charset=utf-8"
+
+			if filepath.Ext(sample) == ".json" {
+				contentType = "application/json"
+			}
+
+			dir, flavour, err := parseListing(page, contentType, f)
+
+			if err != nil {
+				t.Fatal("parseListing failed:", err)
+			}
+
+			got := formatListing(dir, flavour)
+			golden := sample + ".golden"
+
+			if *updateGolden {
+				if err := os.WriteFile(golden, []byte(got), 0o644); err != nil {
+					t.Fatal("Unable to write golden file:", err)
+				}
+			}
+
+			expected, err := os.ReadFile(golden)
+
+			if err != nil {
+				t.Fatal("Unable to read golden file:", err)
+			}
+
+			if got != string(expected) {
+				t.Fatalf("Listing differs from %s:\n%s", golden, got)
+			}
+		})
+	}
+}
+
+func formatListing(dir listing, flavour listingFlavour) string {
+	var b strings.Builder
+
+	fmt.Fprintf(&b, "flavour %s\n", flavour)
+
+	for _, file := range dir.files {
+		size, modTime := "-", "-"
+
+		if info, ok := dir.info[file.String()]; ok {
+			if info.Size >= 0 {
+				size = fmt.Sprint(info.Size)
+			}
+
+			if !info.ModTime.IsZero() {
+				modTime = info.ModTime.Format(time.RFC3339)
+			}
+		}
+
+		fmt.Fprintf(&b, "file %s %s %s\n", file, size, modTime)
+	}
+
+	for _, subdir := range dir.dirs {
+		fmt.Fprintf(&b, "dir %s\n", subdir)
+	}
+
+	return b.String()
+}
+
+func TestParseListingSize(t *testing.T) {
+	tests := map[string]int64{
+		"512":     512,
+		"1.2M":    1258291,
+		"1229K":   1258496,
+		"0.5k":    512,
+		"2G":      2147483648,
+		"-":       -1,
+		"1.2.3":   -1,
+		"text/js": -1,
+	}
+
+	for input, expected := range tests {
+		if size := parseListingSize(input); size != expected {
+			t.Fatal("Incorrect size for", input, size)
+		}
+	}
+}
+
+func TestListHttpCaddyJson(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Server", "Caddy")
+
+		if strings.HasPrefix(r.Header.Get("Accept"), "application/json") {
+			w.Header().Set("Content-Type", "application/json")
+			fmt.Fprint(w, `[{"name":"foo-1.0.tar.gz","size":512,"url":"./foo-1.0.tar.gz","mod_time":"2024-01-31T13:00:00Z","is_dir":false}]`)
+			return
+		}
+
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		fmt.Fprint(w, `<a href="./foo-1.0.tar.gz">foo-1.0.tar.gz</a>`)
+	}))
+	defer server.Close()
+
+	c := NewCrawler(1)
+
+	site, _ := url.Parse(server.URL + "/pub/")
+
+	dir, err := c.listHttp(context.Background(), site)
+
+	if err != nil {
+		t.Fatal("listHttp failed:", err)
+	}
+
+	if len(dir.files) != 1 || len(dir.info) != 0 {
+		t.Fatal("Expected HTML listing before the server was known:", dir.files, dir.info)
+	}
+
+	dir, err = c.listHttp(context.Background(), site)
+
+	if err != nil {
+		t.Fatal("listHttp failed:", err)
+	}
+
+	if info, ok := dir.info[server.URL+"/pub/foo-1.0.tar.gz"]; !ok || info.Size != 512 {
+		t.Fatal("Expected JSON listing from Caddy:", dir.info)
+	}
+}
@@ -0,0 +1,93 @@
+package crawler
+
+import (
+	"fmt"
+	"log/slog"
+	"net/http"
+	"net/url"
+
+	"github.com/samott/portscout2/types"
+)
+
+// Returns the cached listing of a site, if there is one. Cache
+// errors aren't fatal; we just fetch the listing in full.
+func (c *Crawler) cachedListing(site *url.URL) *types.Listing {
+	if c.listings == nil {
+		return nil
+	}
+
+	listing, err := c.listings.Get(site)
+
+	if err != nil {
+		slog.Warn("Error reading listing cache", "site", site.String(), "err", err)
+		return nil
+	}
+
+	return listing
+}
+
+/**
+ * Caches a freshly fetched listing, provided the server sent a
+ * validator we can use next time; otherwise there's no way to
+ * tell whether it has changed.
+ */
+func (c *Crawler) storeListing(site *url.URL, header http.Header, dir listing) {
+	if c.listings == nil {
+		return
+	}
+
+	cached := types.Listing{
+		Url:          site.String(),
+		ETag:         header.Get("ETag"),
+		LastModified: header.Get("Last-Modified"),
+		Files:        make([]string, 0, len(dir.files)),
+		FileInfo:     dir.info,
+		Dirs:         make([]string, 0, len(dir.dirs)),
+	}
+
+	if cached.ETag == "" && cached.LastModified == "" {
+		return
+	}
+
+	for _, file := range dir.files {
+		cached.Files = append(cached.Files, file.String())
+	}
+
+	for _, subdir := range dir.dirs {
+		cached.Dirs = append(cached.Dirs, subdir.String())
+	}
+
+	if err := c.listings.Put(cached); err != nil {
+		slog.Warn("Error writing listing cache", "site", site.String(), "err", err)
+	}
+}
+
+func listingUrls(cached *types.Listing) (listing, error) {
+	dir := listing{
+		files: make([]*url.URL, 0, len(cached.Files)),
+		info:  cached.FileInfo,
+		dirs:  make([]*url.URL, 0, len(cached.Dirs)),
+	}
+
+	for _, file := range cached.Files {
+		u, err := url.Parse(file)
+
+		if err != nil {
+			return listing{}, fmt.Errorf("Invalid cached link: %w", err)
+		}
+
+		dir.files = append(dir.files, u)
+	}
+
+	for _, subdir := range cached.Dirs {
+		u, err := url.Parse(subdir)
+
+		if err != nil {
+			return listing{}, fmt.Errorf("Invalid cached link: %w", err)
+		}
+
+		dir.dirs = append(dir.dirs, u)
+	}
+
+	return dir, nil
+}
@@ -0,0 +1,72 @@
+package crawler
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+
+	"github.com/samott/portscout2/types"
+)
+
+type fakeListingCache map[string]types.Listing
+
+func (c fakeListingCache) Get(site *url.URL) (*types.Listing, error) {
+	listing, ok := c[site.String()]
+
+	if !ok {
+		return nil, nil
+	}
+
+	return &listing, nil
+}
+
+func (c fakeListingCache) Put(listing types.Listing) error {
+	c[listing.Url] = listing
+	return nil
+}
+
+func TestListHttpConditional(t *testing.T) {
+	fullResponses := 0
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("If-None-Match") == `"v1"` {
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+
+		fullResponses++
+
+		w.Header().Set("ETag", `"v1"`)
+		w.Write([]byte(`<a href="foo-1.0.tar.gz">foo-1.0.tar.gz</a><a href="old/">old/</a>`))
+	}))
+	defer server.Close()
+
+	site, _ := url.Parse(server.URL + "/pub/")
+
+	c := NewCrawler(1)
+	cache := fakeListingCache{}
+
+	c.SetListingCache(cache)
+
+	for range 2 {
+		dir, err := c.listHttp(context.Background(), site)
+
+		if err != nil {
+			t.Fatal("listHttp failed:", err)
+		}
+
+		if len(dir.files) != 1 || dir.files[0].String() != server.URL+"/pub/foo-1.0.tar.gz" {
+			t.Fatal("Incorrect files:", dir.files)
+		}
+
+		if len(dir.dirs) != 1 || dir.dirs[0].String() != server.URL+"/pub/old/" {
+			t.Fatal("Incorrect dirs:", dir.dirs)
+		}
+	}
+
+	if fullResponses != 1 {
+		t.Fatal("Listing fetched in full more than once:", fullResponses)
+	}
+}
@@ -0,0 +1,94 @@
+package crawler
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"strings"
+
+	"github.com/samott/portscout2/version"
+)
+
+const cpanDefaultApiUrl = "https://fastapi.metacpan.org/v1"
+
+/**
+ * Crawls CPAN distributions, planned as
+ * https://metacpan.org/dist/<distribution>. Rather than list the
+ * (large, slow) author directories on the mirrors, we ask a
+ * MetaCPAN-compatible API for the distribution's latest release.
+ * Its version is Perl's, which may need converting (see Crawl).
+ */
+type CpanHandler struct {
+	crawler *Crawler
+	apiUrl  *url.URL
+}
+
+type cpanRelease struct {
+	Distribution string `json:"distribution"`
+	Version      string `json:"version"`
+	DownloadUrl  string `json:"download_url"`
+	Maturity     string `json:"maturity"`
+}
+
+/**
+ * Creates a handler for the MetaCPAN API at apiUrl, or
+ * https://fastapi.metacpan.org/v1 if empty. Only its release
+ * endpoint is used, which returns the latest release by default.
+ */
+func NewCpanHandler(c *Crawler, apiUrl string) (*CpanHandler, error) {
+	u, err := parseApiUrl(apiUrl, cpanDefaultApiUrl, "CPAN API")
+
+	if err != nil {
+		return nil, err
+	}
+
+	return &CpanHandler{
+		crawler: c,
+		apiUrl:  u,
+	}, nil
+}
+
+/**
+ * Reports the distribution's latest release, with its version
+ * expressed in the port's convention (see version.PerlVersion).
+ * Nothing is reported if it's older than the port by Perl's rules,
+ * as it couldn't then be compared reliably.
+ */
+func (h *CpanHandler) Crawl(ctx context.Context, job CrawlJob, result *CrawlResult) error {
+	dist, ok := strings.CutPrefix(strings.TrimSuffix(job.Site.Path, "/"), "/dist/")
+
+	if !ok || dist == "" || strings.Contains(dist, "/") {
+		return fmt.Errorf("Not a CPAN distribution URL: %s", job.Site)
+	}
+
+	var release cpanRelease
+
+	_, err := h.crawler.fetchJson(ctx, h.apiUrl.JoinPath("release", dist), nil, &release)
+
+	if err != nil {
+		return fmt.Errorf("CPAN query failed: %w", err)
+	}
+
+	if release.Maturity == "developer" && job.Port.Config.SkipBeta {
+		return nil
+	}
+
+	current := job.Port.DistVersion
+
+	if version.ComparePerl(release.Version, current) < 0 {
+		return nil
+	}
+
+	file, err := url.Parse(release.DownloadUrl)
+
+	if err != nil {
+		return fmt.Errorf("Invalid CPAN download URL: %w", err)
+	}
+
+	result.Releases = append(result.Releases, Release{
+		Version: version.PerlVersion(release.Version, current),
+		File:    file,
+	})
+
+	return nil
+}
@@ -0,0 +1,115 @@
+package crawler
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/samott/portscout2/types"
+)
+
+func TestCpanHandler(t *testing.T) {
+	latest := "1.3"
+
+	mux := http.NewServeMux()
+
+	mux.HandleFunc("GET /v1/release/Foo-Bar", func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprintf(w, `{
+			"distribution": "Foo-Bar",
+			"version": %q,
+			"download_url": "https://cpan.metacpan.org/authors/id/A/AU/AUTHOR/Foo-Bar-%s.tar.gz",
+			"maturity": "released"
+		}`, latest, latest)
+	})
+
+	server := httptest.NewServer(mux)
+	defer server.Close()
+
+	c := NewCrawler(1)
+
+	h, err := NewCpanHandler(c, server.URL+"/v1")
+
+	if err != nil {
+		t.Fatal("NewCpanHandler failed:", err)
+	}
+
+	site, _ := url.Parse("https://metacpan.org/dist/Foo-Bar")
+
+	job := CrawlJob{
+		Port: types.PortInfo{
+			DistName:    "Foo-Bar-1.23",
+			DistVersion: "1.23",
+		},
+		Site: site,
+	}
+
+	var result CrawlResult
+
+	err = h.Crawl(context.Background(), job, &result)
+
+	if err != nil {
+		t.Fatal("Crawl failed:", err)
+	}
+
+	if len(result.Releases) != 1 {
+		t.Fatal("Incorrect release count:", result.Releases)
+	}
+
+	release := result.Releases[0]
+
+	if release.Version != "1.30" {
+		t.Fatal("Incorrect version:", release.Version)
+	}
+
+	if release.File.String() != "https://cpan.metacpan.org/authors/id/A/AU/AUTHOR/Foo-Bar-1.3.tar.gz" {
+		t.Fatal("Incorrect file:", release.File)
+	}
+
+	// Older by Perl's rules, though not by pkg_version's
+	latest = "1.225"
+	result = CrawlResult{}
+
+	err = h.Crawl(context.Background(), job, &result)
+
+	if err != nil {
+		t.Fatal("Crawl failed:", err)
+	}
+
+	if len(result.Releases) != 0 {
+		t.Fatal("Expected no releases:", result.Releases)
+	}
+
+	job.Site, _ = url.Parse("https://metacpan.org/pod/Foo::Bar")
+	result = CrawlResult{}
+
+	err = h.Crawl(context.Background(), job, &result)
+
+	if err == nil || !strings.Contains(err.Error(), "Not a CPAN distribution URL") {
+		t.Fatal("Expected error for module page; got:", err)
+	}
+
+	job.Site, _ = url.Parse("https://metacpan.org/dist/")
+	result = CrawlResult{}
+
+	err = h.Crawl(context.Background(), job, &result)
+
+	if err == nil || !strings.Contains(err.Error(), "Not a CPAN distribution URL") {
+		t.Fatal("Expected error for no distribution name; got:", err)
+	}
+
+	job.Site, _ = url.Parse("https://metacpan.org/dist/Missing")
+	result = CrawlResult{}
+
+	err = h.Crawl(context.Background(), job, &result)
+
+	var statusErr *StatusError
+
+	if !errors.As(err, &statusErr) || statusErr.Code != http.StatusNotFound {
+		t.Fatal("Expected not found error for unknown distribution; got:", err)
+	}
+}
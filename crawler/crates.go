@@ -0,0 +1,201 @@
+package crawler
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/url"
+	"strings"
+	"sync"
+)
+
+const cratesDefaultIndexUrl = "https://index.crates.io"
+
+/**
+ * Crawls Rust crates, planned as https://crates.io/crates/<name>.
+ * Rather than use the crates.io API, which has strict limits for
+ * crawlers, versions are read from the registry's sparse index, in
+ * which each crate has a file listing every published version, one
+ * JSON object per line.
+ */
+type CratesHandler struct {
+	crawler  *Crawler
+	indexUrl *url.URL
+
+	mu sync.Mutex
+	dl string
+}
+
+type crateVersion struct {
+	Name   string `json:"name"`
+	Vers   string `json:"vers"`
+	Yanked bool   `json:"yanked"`
+}
+
+type cratesConfig struct {
+	Dl string `json:"dl"`
+}
+
+/**
+ * Creates a handler for the sparse index at indexUrl, by default
+ * https://index.crates.io. Alternative registries work too, since
+ * the download location is read from the index's config.json.
+ */
+func NewCratesHandler(c *Crawler, indexUrl string) (*CratesHandler, error) {
+	u, err := parseApiUrl(indexUrl, cratesDefaultIndexUrl, "crates index")
+
+	if err != nil {
+		return nil, err
+	}
+
+	return &CratesHandler{
+		crawler:  c,
+		indexUrl: u,
+	}, nil
+}
+
+/**
+ * Reports every version which hasn't been yanked, skipping
+ * pre-releases ("1.0.0-rc.1") if the port skips betas.
+ */
+func (h *CratesHandler) Crawl(ctx context.Context, job CrawlJob, result *CrawlResult) error {
+	name, ok := strings.CutPrefix(strings.TrimSuffix(job.Site.Path, "/"), "/crates/")
+
+	if !ok || name == "" || strings.Contains(name, "/") {
+		return fmt.Errorf("Not a crate URL: %s", job.Site)
+	}
+
+	dl, err := h.downloadTemplate(ctx)
+
+	if err != nil {
+		return err
+	}
+
+	versions := make([]crateVersion, 0)
+
+	_, err = h.crawler.fetchApi(ctx, h.indexUrl.JoinPath(crateIndexPath(name)...), nil, func(r io.Reader) error {
+		scanner := bufio.NewScanner(r)
+		scanner.Buffer(nil, 1<<20)
+
+		for scanner.Scan() {
+			var ver crateVersion
+
+			if err := json.Unmarshal(scanner.Bytes(), &ver); err != nil {
+				return err
+			}
+
+			versions = append(versions, ver)
+		}
+
+		return scanner.Err()
+	})
+
+	if err != nil {
+		return fmt.Errorf("Crates index query failed: %w", err)
+	}
+
+	for _, ver := range versions {
+		if ver.Yanked || (job.Port.Config.SkipBeta && strings.Contains(ver.Vers, "-")) {
+			continue
+		}
+
+		file, err := url.Parse(crateDownloadUrl(dl, ver.Name, ver.Vers))
+
+		if err != nil {
+			continue
+		}
+
+		result.Releases = append(result.Releases, Release{
+			Version: semverVersion(ver.Vers, job.Port.DistVersion),
+			File:    file,
+		})
+	}
+
+	return nil
+}
+
+// Reads the download URL template from the index's config.json.
+func (h *CratesHandler) downloadTemplate(ctx context.Context) (string, error) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if h.dl != "" {
+		return h.dl, nil
+	}
+
+	var config cratesConfig
+
+	_, err := h.crawler.fetchJson(ctx, h.indexUrl.JoinPath("config.json"), nil, &config)
+
+	if err != nil {
+		return "", fmt.Errorf("Crates index config query failed: %w", err)
+	}
+
+	if config.Dl == "" {
+		return "", fmt.Errorf("Crates index config has no download URL")
+	}
+
+	h.dl = config.Dl
+
+	return h.dl, nil
+}
+
+/**
+ * Returns the path of a crate's file in the index, which is
+ * sharded by the start of its (lower case) name: "1/a", "2/ab",
+ * "3/a/abc" and "ab/cd/abcd...".
+ */
+func crateIndexPath(name string) []string {
+	name = strings.ToLower(name)
+
+	return append(cratePrefix(name), name)
+}
+
+func cratePrefix(name string) []string {
+	switch len(name) {
+	case 1:
+		return []string{"1"}
+	case 2:
+		return []string{"2"}
+	case 3:
+		return []string{"3", name[:1]}
+	default:
+		return []string{name[:2], name[2:4]}
+	}
+}
+
+/**
+ * Expands the index's download template, which may contain markers
+ * for the crate, version and index prefix; without any, the path
+ * "/{crate}/{version}/download" is appended.
+ */
+func crateDownloadUrl(dl string, name string, ver string) string {
+	if !strings.Contains(dl, "{") {
+		return strings.TrimSuffix(dl, "/") + "/" + name + "/" + ver + "/download"
+	}
+
+	return strings.NewReplacer(
+		"{crate}", name,
+		"{version}", ver,
+		"{prefix}", strings.Join(cratePrefix(name), "/"),
+		"{lowerprefix}", strings.Join(cratePrefix(strings.ToLower(name)), "/"),
+	).Replace(dl)
+}
+
+/**
+ * Expresses a semantic version ("v1.2.3", as used by Go modules,
+ * or "1.2.3") in the same form as the port's current version, i.e.
+ * with or without the "v", and without build metadata ("+abc").
+ */
+func semverVersion(ver string, current string) string {
+	ver, _, _ = strings.Cut(ver, "+")
+	ver = strings.TrimPrefix(ver, "v")
+
+	if strings.HasPrefix(current, "v") {
+		return "v" + ver
+	}
+
+	return ver
+}
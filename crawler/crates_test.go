@@ -0,0 +1,158 @@
+package crawler
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/samott/portscout2/types"
+)
+
+func TestCratesHandler(t *testing.T) {
+	var server *httptest.Server
+
+	mux := http.NewServeMux()
+
+	mux.HandleFunc("GET /config.json", func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprintf(w, `{"dl": "%s/crates/{crate}/{crate}-{version}.crate", "api": "%s"}`, server.URL, server.URL)
+	})
+
+	mux.HandleFunc("GET /ri/pg/ripgrep", func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, `{"name": "ripgrep", "vers": "14.0.0", "yanked": false}
+{"name": "ripgrep", "vers": "14.1.0", "yanked": false}
+{"name": "ripgrep", "vers": "14.1.1", "yanked": true}
+{"name": "ripgrep", "vers": "15.0.0-rc.1", "yanked": false}
+`)
+	})
+
+	mux.HandleFunc("GET /em/pt/empty", func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, `{"name": "empty", "vers": "0.1.0", "yanked": true}
+`)
+	})
+
+	mux.HandleFunc("GET /3/b/bad", func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, "<html>Not an index file</html>\n")
+	})
+
+	server = httptest.NewServer(mux)
+	defer server.Close()
+
+	c := NewCrawler(1)
+
+	h, err := NewCratesHandler(c, server.URL)
+
+	if err != nil {
+		t.Fatal("NewCratesHandler failed:", err)
+	}
+
+	site, _ := url.Parse("https://crates.io/crates/ripgrep")
+
+	job := CrawlJob{
+		Port: types.PortInfo{
+			DistVersion: "14.0.0",
+			Config: types.PortConfig{
+				SkipBeta: true,
+			},
+		},
+		Site: site,
+	}
+
+	var result CrawlResult
+
+	err = h.Crawl(context.Background(), job, &result)
+
+	if err != nil {
+		t.Fatal("Crawl failed:", err)
+	}
+
+	versions := make([]string, 0)
+
+	for _, release := range result.Releases {
+		versions = append(versions, release.Version)
+	}
+
+	if !slices.Equal(versions, []string{"14.0.0", "14.1.0"}) {
+		t.Fatal("Unexpected versions:", versions)
+	}
+
+	if file := result.Releases[1].File.String(); file != server.URL+"/crates/ripgrep/ripgrep-14.1.0.crate" {
+		t.Fatal("Unexpected file:", file)
+	}
+
+	job.Site, _ = url.Parse("https://crates.io/crates/")
+	result = CrawlResult{}
+
+	err = h.Crawl(context.Background(), job, &result)
+
+	if err == nil || !strings.Contains(err.Error(), "Not a crate URL") {
+		t.Fatal("Expected error for no crate name; got:", err)
+	}
+
+	job.Site, _ = url.Parse("https://crates.io/crates/ripgrep/versions")
+	result = CrawlResult{}
+
+	err = h.Crawl(context.Background(), job, &result)
+
+	if err == nil || !strings.Contains(err.Error(), "Not a crate URL") {
+		t.Fatal("Expected error for versions page; got:", err)
+	}
+
+	job.Site, _ = url.Parse("https://crates.io/crates/missing")
+	result = CrawlResult{}
+
+	err = h.Crawl(context.Background(), job, &result)
+
+	var statusErr *StatusError
+
+	if !errors.As(err, &statusErr) || statusErr.Code != http.StatusNotFound {
+		t.Fatal("Expected not found error for unknown crate; got:", err)
+	}
+
+	job.Site, _ = url.Parse("https://crates.io/crates/bad")
+	result = CrawlResult{}
+
+	err = h.Crawl(context.Background(), job, &result)
+
+	if err == nil || !strings.Contains(err.Error(), "Crates index query failed") {
+		t.Fatal("Expected error for malformed index file; got:", err)
+	}
+
+	job.Site, _ = url.Parse("https://crates.io/crates/empty")
+	result = CrawlResult{}
+
+	err = h.Crawl(context.Background(), job, &result)
+
+	if err != nil {
+		t.Fatal("Crawl failed:", err)
+	}
+
+	if len(result.Releases) != 0 {
+		t.Fatal("Expected yanked versions to be ignored:", result.Releases)
+	}
+}
+
+func TestCrateIndexPath(t *testing.T) {
+	tests := map[string]string{
+		"a":       "1/a",
+		"ab":      "2/ab",
+		"abc":     "3/a/abc",
+		"Serde":   "se/rd/serde",
+		"ripgrep": "ri/pg/ripgrep",
+	}
+
+	for name, expected := range tests {
+		if path := strings.Join(crateIndexPath(name), "/"); path != expected {
+			t.Fatal("Incorrect index path for", name, path)
+		}
+	}
+
+	if u := crateDownloadUrl("https://static.crates.io/crates", "serde", "1.0.0"); u != "https://static.crates.io/crates/serde/1.0.0/download" {
+		t.Fatal("Incorrect default download URL:", u)
+	}
+}
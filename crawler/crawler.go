@@ -3,56 +3,146 @@ package crawler
 import (
 	"context"
 	"errors"
-
-	"io"
 	"fmt"
-	"net/url"
+	"log/slog"
+	"maps"
 	"net/http"
+	"net/url"
+	"strings"
 	"sync"
 	"time"
 
-	"github.com/jlaffaye/ftp"
-
 	"github.com/samott/portscout2/types"
 )
 
 type Crawler struct {
-	ftpTimeout time.Duration
-	limiter    CrawlLimiterInterface
-	in         chan CrawlJob
-	out        chan CrawlResult
+	requestTimeout time.Duration
+	siteTimeout    time.Duration
+	guess          bool
+	limiter        CrawlLimiterInterface
+	hosts          HostTrackerInterface
+	retryPolicies  map[ErrorClass]RetryPolicy
+	listings       ListingCacheInterface
+	memo           *listingMemo
+	robots         *robotsPolicy
+	ftpOptions     FtpOptions
+	ftpPool        *ftpPool
+	jsonHosts      sync.Map
+	handlersMu     sync.RWMutex
+	handlers       []handlerEntry
+	in             chan CrawlJob
+	out            chan CrawlResult
 }
 
 type CrawlLimiterInterface interface {
-	Wait(site *url.URL, ctx context.Context)
+	Wait(site *url.URL, ctx context.Context) error
+	Done(site *url.URL, latency time.Duration, throttled bool)
+	Backoff(site *url.URL, d time.Duration)
+	SetMinInterval(site *url.URL, d time.Duration)
 }
 
+type ListingCacheInterface interface {
+	Get(site *url.URL) (*types.Listing, error)
+	Put(listing types.Listing) error
+}
+
+type HostTrackerInterface interface {
+	RecordSuccess(hostname string)
+	RecordFailure(hostname string, err error)
+}
+
+// Mirrors are alternatives to Site, tried in order if it fails.
 type CrawlJob struct {
-	Port types.PortInfo
-	Site *url.URL
-	File string
+	Port    types.PortInfo
+	Site    *url.URL
+	Mirrors []*url.URL
+	File    string
 }
 
+/**
+ * Site is the mirror which answered, or the last one tried.
+ * FileInfo holds the size and modification time of those Files
+ * for which the listing gave them, keyed by URL.
+ */
 type CrawlResult struct {
-	Port  types.PortName
-	Site  *url.URL
-	Files []*url.URL
-	Err   error
+	Port     types.PortName
+	Site     *url.URL
+	Files    []*url.URL
+	FileInfo map[string]types.FileInfo
+	Releases []Release
+	Err      error
+}
+
+// Release is a version reported directly by an upstream API,
+// rather than one which has to be extracted from a filename.
+type Release struct {
+	Version string
+	File    *url.URL
 }
 
 func NewCrawler(chanBufSize int) *Crawler {
-	return &Crawler{
-		in:         make(chan CrawlJob, chanBufSize),
-		out:        make(chan CrawlResult, chanBufSize),
-		ftpTimeout: 30 * time.Second,
-		limiter:    nil,
+	c := &Crawler{
+		in:             make(chan CrawlJob, chanBufSize),
+		out:            make(chan CrawlResult, chanBufSize),
+		requestTimeout: 30 * time.Second,
+		siteTimeout:    2 * time.Minute,
+		limiter:        nil,
+		memo:           newListingMemo(),
+		robots:         newRobotsPolicy(),
+		ftpOptions:     FtpOptions{Tls: FtpTlsTry, IdleTimeout: 30 * time.Second},
+		ftpPool:        newFtpPool(),
+		retryPolicies:  maps.Clone(defaultRetryPolicies),
 	}
+
+	c.RegisterHandler("http", "", HandlerFunc(c.crawlHttp))
+	c.RegisterHandler("https", "", HandlerFunc(c.crawlHttp))
+	c.RegisterHandler("ftp", "", HandlerFunc(c.crawlFtp))
+
+	return c
 }
 
 func (c *Crawler) SetLimiter(limiter CrawlLimiterInterface) {
 	c.limiter = limiter
 }
 
+/**
+ * Sets the deadline for each individual request (an HTTP request
+ * or FTP connection), and the total time allowed for crawling a
+ * single site, which may involve several requests. Zero leaves
+ * the existing value in place.
+ */
+func (c *Crawler) SetTimeouts(request time.Duration, site time.Duration) {
+	if request > 0 {
+		c.requestTimeout = request
+	}
+
+	if site > 0 {
+		c.siteTimeout = site
+	}
+}
+
+/**
+ * Enables conditional requests for HTTP listings, with unchanged
+ * listings served from the cache.
+ */
+func (c *Crawler) SetListingCache(listings ListingCacheInterface) {
+	c.listings = listings
+}
+
+// The outcome of each crawl attempt is reported to the tracker.
+func (c *Crawler) SetHostTracker(hosts HostTrackerInterface) {
+	c.hosts = hosts
+}
+
+/**
+ * Enables guess mode, in which sites that can't be listed (or whose
+ * listings don't contain the port's distfiles) are probed for files
+ * named after likely next versions.
+ */
+func (c *Crawler) SetGuess(enabled bool) {
+	c.guess = enabled
+}
+
 func (c *Crawler) In() chan<- CrawlJob {
 	return c.in
 }
@@ -61,164 +151,204 @@ func (c *Crawler) Out() <-chan CrawlResult {
 	return c.out
 }
 
-func (c *Crawler) Run() {
+/**
+ * Crawls jobs from In until it is closed, sending one result per
+ * job to Out, which is closed once all jobs have completed. Each
+ * listing is fetched at most once per run, however many jobs
+ * need it.
+ *
+ * If the context is cancelled, crawls in progress are abandoned
+ * and jobs still arriving on In are answered with the context's
+ * error rather than crawled, so callers waiting on results for
+ * specific jobs aren't left hanging. Callers should keep reading
+ * Out until it is closed.
+ */
+func (c *Crawler) Run(ctx context.Context) {
 	var wg sync.WaitGroup
 
 	for r := range c.in {
-		if r.Site.Scheme == "http" {
-			wg.Add(1)
-			go func() {
-				defer wg.Done()
-
-				files, err := c.crawlHttp(r.Port, r.Site)
-				c.out <- CrawlResult{
-					Port:  r.Port.Name,
-					Site:  r.Site,
-					Files: files,
-					Err:   err,
-				}
-			}()
-			continue
-		}
-
-		if r.Site.Scheme == "ftp" {
-			wg.Add(1)
-			go func() {
-				defer wg.Done()
-
-				files, err := c.crawlFtp(r.Port, r.Site)
-				c.out <- CrawlResult{
-					Port:  r.Port.Name,
-					Site:  r.Site,
-					Files: files,
-					Err:   err,
-				}
-			}()
+		if ctx.Err() != nil {
+			c.out <- CrawlResult{
+				Port: r.Port.Name,
+				Site: r.Site,
+				Err:  ctx.Err(),
+			}
 			continue
 		}
 
-		// No suitable handler found
-		c.out <- CrawlResult{
-			Port:  r.Port.Name,
-			Site:  r.Site,
-			Files: nil,
-			Err:   errors.New("Unhandled site scheme or format"),
-		}
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			c.out <- c.crawl(ctx, r)
+		}()
 	}
 
 	wg.Wait()
+
+	c.memo.report()
+	c.closeIdleFtp()
+
 	close(c.out)
 }
 
-/*
-func (c *Crawler) getVersionRootFromPath(port types.PortInfo, site *url.URL) (string) {
-	segments := strings.Split(u.Path, "/")
-
-	// We are looking to see if a major version is embedded in the path,
-	// e.g. http://example.net/4.3/releases/file-4.3.2.zip so that when
-	// we access the URL we can update this instance of the version.
-	// We can handle multiple copies of the version, including different
-	// levels of truncation (4.3.2, 4.3) but we'll make the assumption
-	// that the first instance is the shortest (most "major" number).
-	for segment := range segments {
-		if len(segment) == 0 {
+/**
+ * Crawls the job's site, failing over to each of its mirrors in
+ * turn if it can't be crawled (after any retries) or doesn't finish
+ * within the site timeout. Each attempt starts with a fresh result,
+ * so nothing from a failed mirror leaks into the answer from a
+ * working one.
+ */
+func (c *Crawler) crawl(ctx context.Context, job CrawlJob) CrawlResult {
+	sites := append([]*url.URL{job.Site}, job.Mirrors...)
+	errs := make([]error, 0)
+
+	var result CrawlResult
+
+	for _, site := range sites {
+		attempt := job
+		attempt.Site = site
+
+		handler := c.handlerFor(site)
+
+		if handler == nil {
+			// No suitable handler found
+			result = CrawlResult{
+				Port: job.Port.Name,
+				Site: site,
+				Err:  errors.New("Unhandled site scheme or format"),
+			}
+			errs = append(errs, fmt.Errorf("%s: %w", site, result.Err))
 			continue
 		}
 
-		if strings.ContainsRune(segment, '.') && strings.HasPrefix(port.Version, segment) {
-			return segment;
+		result = c.crawlWithRetry(ctx, handler, attempt)
+
+		if ctx.Err() != nil {
+			// Cancelled; not the site's fault
+			result.Err = ctx.Err()
+			return result
 		}
-	}
 
-	return "";
-}
-*/
+		if result.Err == nil {
+			if c.hosts != nil {
+				c.hosts.RecordSuccess(site.Hostname())
+			}
 
-func (c *Crawler) crawlFtp(port types.PortInfo, site *url.URL) ([]*url.URL, error) {
-	files := make([]*url.URL, 0)
+			return result
+		}
 
-	if c.limiter != nil {
-		c.limiter.Wait(site, context.Background())
+		slog.Debug("Mirror failed", "port", job.Port.Name, "site", site.String(), "err", result.Err)
+
+		// Permanent errors such as a 404 show the host is up
+		if class, _ := classifyError(result.Err); c.hosts != nil && class != ErrorPermanent {
+			c.hosts.RecordFailure(site.Hostname(), result.Err)
+		}
+
+		errs = append(errs, fmt.Errorf("%s: %w", site, result.Err))
 	}
 
-	// For some reason the library doesn't use the default
-	// FTP port if none is provided in the URL
-	if site.Port() == "" {
-		site.Host = site.Hostname() + ":21"
+	if len(errs) > 1 {
+		result.Err = errors.Join(errs...)
 	}
 
-	client, err := ftp.Dial(site.Host, ftp.DialWithTimeout(c.ftpTimeout))
+	return result
+}
 
-	if err != nil {
-		return nil, fmt.Errorf("FTP dial failed: %w", err)
-	}
+func (c *Crawler) crawlHttp(ctx context.Context, job CrawlJob, result *CrawlResult) error {
+	list := c.shared(c.listHttp)
 
-	err = client.Login("anonymous", "anonymous")
+	dir, err := list(ctx, job.Site)
 
-	if err != nil {
-		return nil, fmt.Errorf("FTP login failed: %w", err)
+	if err == nil {
+		dir.addFiles(c.descend(ctx, job, list))
+		result.addListing(dir)
 	}
 
-	err = client.ChangeDir(site.Path)
+	return c.guessIfNeeded(ctx, job, result, err, c.probeHttp)
+}
 
-	if err != nil {
-		return nil, fmt.Errorf("FTP cwd failed: %w", err)
+func (r *CrawlResult) addListing(dir listing) {
+	r.Files = append(r.Files, dir.files...)
+
+	if len(dir.info) == 0 {
+		return
 	}
 
-	entries, err := client.List(".")
+	if r.FileInfo == nil {
+		r.FileInfo = make(map[string]types.FileInfo, len(dir.info))
+	}
 
-	if err != nil {
-		return nil, fmt.Errorf("FTP list failed: %w", err)
+	maps.Copy(r.FileInfo, dir.info)
+}
+
+/**
+ * Fetches and parses an HTTP directory listing. Caddy can produce
+ * either HTML or JSON, and gives file sizes and dates more reliably
+ * in JSON, so once a host is seen to run Caddy its listings are
+ * requested as JSON.
+ */
+func (c *Crawler) listHttp(ctx context.Context, site *url.URL) (listing, error) {
+	if err := c.checkRobots(ctx, site); err != nil {
+		return listing{}, err
 	}
 
-	for _, entry := range entries {
-		if entry.Type != ftp.EntryTypeFile {
-			continue
-		}
+	req, err := http.NewRequestWithContext(ctx, "GET", site.String(), nil)
 
-		fileUrl := site.JoinPath(site.String(), entry.Name)
+	if err != nil {
+		return listing{}, fmt.Errorf("Error creating request: %w", err)
+	}
 
-		if err != nil {
-			return nil, fmt.Errorf("URL path join failed: %w", err)
-		}
+	req.Header.Set("User-Agent", "portscout/2")
 
-		files = append(files, fileUrl)
+	if _, ok := c.jsonHosts.Load(site.Host); ok {
+		req.Header.Set("Accept", "application/json, text/html;q=0.9")
+	} else {
+		req.Header.Set("Accept", "text/html, application/json;q=0.9")
 	}
 
-	return files, nil
-}
+	cached := c.cachedListing(site)
 
-func (c *Crawler) crawlHttp(port types.PortInfo, site *url.URL) ([]*url.URL, error) {
-	files := make([]*url.URL, 0)
+	if cached != nil {
+		if cached.ETag != "" {
+			req.Header.Set("If-None-Match", cached.ETag)
+		}
 
-	if c.limiter != nil {
-		c.limiter.Wait(site, context.Background())
+		if cached.LastModified != "" {
+			req.Header.Set("If-Modified-Since", cached.LastModified)
+		}
 	}
 
-	req, err := http.NewRequest("GET", site.String(), nil);
+	resp, err := c.httpClient().Do(req)
 
 	if err != nil {
-		return nil, fmt.Errorf("Error creating request: %w", err)
+		return listing{}, fmt.Errorf("Error making request: %w", err)
 	}
 
-	req.Header.Set("User-Agent", "portscout/2")
-	req.Header.Set("Content-Type", "text/html")
-
-	client := &http.Client{}
+	defer resp.Body.Close()
 
-	resp, err := client.Do(req)
+	if strings.HasPrefix(strings.ToLower(resp.Header.Get("Server")), "caddy") {
+		c.jsonHosts.Store(site.Host, true)
+	}
 
-	if err != nil {
-		return nil, fmt.Errorf("Error making request: %w", err)
+	if resp.StatusCode == http.StatusNotModified && cached != nil {
+		return listingUrls(cached)
 	}
 
 	if resp.StatusCode > 299 {
-		return nil, fmt.Errorf("Request not successful: %s", resp.Status)
+		return listing{}, newStatusError(resp)
 	}
 
-	defer resp.Body.Close()
+	// Relative links are resolved against the final URL,
+	// in case we were redirected (e.g. to add a trailing /)
+	dir, flavour, err := parseListing(resp.Request.URL, resp.Header.Get("Content-Type"), resp.Body)
+
+	if err != nil {
+		return listing{}, fmt.Errorf("Error parsing response: %w", err)
+	}
+
+	slog.Debug("Parsed listing", "site", site.String(), "flavour", flavour, "files", len(dir.files), "dirs", len(dir.dirs))
 
-	_, _ = io.ReadAll(resp.Body)
+	c.storeListing(site, resp.Header, dir)
 
-	return files, nil
+	return dir, nil
 }
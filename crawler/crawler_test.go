@@ -0,0 +1,119 @@
+package crawler
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/url"
+	"testing"
+
+	"github.com/samott/portscout2/types"
+)
+
+type fakeHostTracker struct {
+	succeeded []string
+	failed    []string
+}
+
+func (h *fakeHostTracker) RecordSuccess(hostname string) {
+	h.succeeded = append(h.succeeded, hostname)
+}
+
+func (h *fakeHostTracker) RecordFailure(hostname string, err error) {
+	h.failed = append(h.failed, hostname)
+}
+
+func TestRunFailover(t *testing.T) {
+	c := NewCrawler(1)
+	hosts := &fakeHostTracker{}
+
+	c.SetHostTracker(hosts)
+	c.SetRetryPolicy(ErrorTransient, RetryPolicy{MaxAttempts: 1})
+
+	c.RegisterHandler("test", "", HandlerFunc(func(ctx context.Context, job CrawlJob, result *CrawlResult) error {
+		if job.Site.Hostname() != "good.example.net" {
+			result.Files = append(result.Files, job.Site.JoinPath("stale-1.0.tar.gz"))
+			return &StatusError{Code: http.StatusBadGateway, Status: "502 Bad Gateway"}
+		}
+
+		result.Files = append(result.Files, job.Site.JoinPath("foo-1.1.tar.gz"))
+
+		return nil
+	}))
+
+	bad, _ := url.Parse("test://bad.example.net/pub/")
+	unhandled, _ := url.Parse("rsync://rsync.example.net/pub/")
+	good, _ := url.Parse("test://good.example.net/pub/")
+
+	go c.Run(context.Background())
+
+	c.In() <- CrawlJob{
+		Port:    types.PortInfo{Name: types.PortName{Category: "cat", Name: "test"}},
+		Site:    bad,
+		Mirrors: []*url.URL{unhandled, good},
+	}
+	close(c.In())
+
+	result := <-c.Out()
+
+	if result.Err != nil {
+		t.Fatal("Unexpected error:", result.Err)
+	}
+
+	if result.Site != good {
+		t.Fatal("Incorrect answering site:", result.Site)
+	}
+
+	if len(result.Files) != 1 || result.Files[0].String() != "test://good.example.net/pub/foo-1.1.tar.gz" {
+		t.Fatal("Incorrect files:", result.Files)
+	}
+
+	if len(hosts.succeeded) != 1 || hosts.succeeded[0] != "good.example.net" {
+		t.Fatal("Incorrect host successes:", hosts.succeeded)
+	}
+
+	if len(hosts.failed) != 1 || hosts.failed[0] != "bad.example.net" {
+		t.Fatal("Incorrect host failures:", hosts.failed)
+	}
+}
+
+func TestRunCancelled(t *testing.T) {
+	c := NewCrawler(1)
+
+	started := make(chan bool)
+
+	c.RegisterHandler("test", "", HandlerFunc(func(ctx context.Context, job CrawlJob, result *CrawlResult) error {
+		started <- true
+		<-ctx.Done()
+		return ctx.Err()
+	}))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	site, _ := url.Parse("test://slow.example.net/pub/")
+	job := CrawlJob{
+		Port: types.PortInfo{Name: types.PortName{Category: "cat", Name: "test"}},
+		Site: site,
+	}
+
+	go c.Run(ctx)
+
+	c.In() <- job
+	<-started
+	cancel()
+	c.In() <- job
+	close(c.In())
+
+	count := 0
+
+	for result := range c.Out() {
+		if !errors.Is(result.Err, context.Canceled) {
+			t.Fatal("Expected cancellation error:", result.Err)
+		}
+
+		count++
+	}
+
+	if count != 2 {
+		t.Fatal("Incorrect result count:", count)
+	}
+}
@@ -0,0 +1,161 @@
+package crawler
+
+import (
+	"context"
+	"log/slog"
+	"maps"
+	"net/url"
+	"path"
+	"slices"
+	"strings"
+	"sync"
+	"sync/atomic"
+)
+
+/**
+ * Listings fetched during a run, keyed by normalised URL. Many
+ * ports share a master site directory (GNU, KDE, CPAN mirrors and
+ * so on), so each listing is fetched once and handed to every job
+ * which asks for it, including jobs which ask while the fetch is
+ * still in progress.
+ *
+ * Only successful listings are kept; a failure is shared with
+ * anyone already waiting, but the next request tries again.
+ */
+type listingMemo struct {
+	mu      sync.Mutex
+	entries map[string]*memoEntry
+	fetched atomic.Int64
+	shared  atomic.Int64
+}
+
+type memoEntry struct {
+	done    chan struct{}
+	listing listing
+	err     error
+}
+
+func newListingMemo() *listingMemo {
+	return &listingMemo{
+		entries: make(map[string]*memoEntry),
+	}
+}
+
+// Wraps a lister so that its listings are shared through the memo.
+func (c *Crawler) shared(list lister) lister {
+	return func(ctx context.Context, site *url.URL) (listing, error) {
+		return c.memo.list(ctx, site, list)
+	}
+}
+
+func (m *listingMemo) list(ctx context.Context, site *url.URL, list lister) (listing, error) {
+	key := normaliseUrl(site)
+
+	m.mu.Lock()
+
+	entry, ok := m.entries[key]
+
+	if ok {
+		m.mu.Unlock()
+
+		select {
+		case <-entry.done:
+		case <-ctx.Done():
+			return listing{}, ctx.Err()
+		}
+
+		if entry.err != nil {
+			return listing{}, entry.err
+		}
+
+		m.shared.Add(1)
+
+		return entry.listing.clone(), nil
+	}
+
+	entry = &memoEntry{
+		done: make(chan struct{}),
+	}
+
+	m.entries[key] = entry
+
+	m.mu.Unlock()
+
+	entry.listing, entry.err = list(ctx, site)
+
+	m.fetched.Add(1)
+
+	if entry.err != nil {
+		m.mu.Lock()
+		delete(m.entries, key)
+		m.mu.Unlock()
+	}
+
+	close(entry.done)
+
+	if entry.err != nil {
+		return listing{}, entry.err
+	}
+
+	return entry.listing.clone(), nil
+}
+
+// Copies a listing, since callers are free to add to it.
+func (l listing) clone() listing {
+	return listing{
+		files: slices.Clone(l.files),
+		info:  maps.Clone(l.info),
+		dirs:  slices.Clone(l.dirs),
+	}
+}
+
+// Logs how many fetches were saved by sharing listings.
+func (m *listingMemo) report() {
+	fetched := m.fetched.Load()
+	shared := m.shared.Load()
+	savedPct := int64(0)
+
+	if fetched+shared > 0 {
+		savedPct = shared * 100 / (fetched + shared)
+	}
+
+	slog.Info("Listings fetched", "fetched", fetched, "shared", shared, "savedPct", savedPct)
+}
+
+/**
+ * Normalises a site URL so that trivially different spellings of
+ * the same directory share a listing: the scheme and host are
+ * lowercased, default ports and fragments dropped, and the path
+ * cleaned (keeping any trailing slash, which matters to servers).
+ */
+func normaliseUrl(site *url.URL) string {
+	u := *site
+
+	u.Scheme = strings.ToLower(u.Scheme)
+	u.Host = strings.ToLower(u.Host)
+	u.Fragment = ""
+	u.RawFragment = ""
+
+	switch {
+	case u.Scheme == "http" && u.Port() == "80",
+		u.Scheme == "https" && u.Port() == "443",
+		u.Scheme == "ftp" && u.Port() == "21":
+		u.Host = strings.TrimSuffix(u.Host, ":"+u.Port())
+	}
+
+	if u.Path == "" {
+		u.Path = "/"
+	} else {
+		cleaned := path.Clean(u.Path)
+
+		if strings.HasSuffix(u.Path, "/") && cleaned != "/" {
+			cleaned += "/"
+		}
+
+		u.Path = cleaned
+	}
+
+	u.RawPath = ""
+
+	return u.String()
+}
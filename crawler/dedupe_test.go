@@ -0,0 +1,108 @@
+package crawler
+
+import (
+	"context"
+	"errors"
+	"net/url"
+	"sync"
+	"sync/atomic"
+	"testing"
+)
+
+func TestNormaliseUrl(t *testing.T) {
+	cases := []struct {
+		site     string
+		expected string
+	}{
+		{"HTTP://WWW.Example.NET/pub/foo/", "http://www.example.net/pub/foo/"},
+		{"http://www.example.net:80/pub//foo/./", "http://www.example.net/pub/foo/"},
+		{"https://www.example.net:443/pub/foo#top", "https://www.example.net/pub/foo"},
+		{"https://www.example.net:8443/pub/", "https://www.example.net:8443/pub/"},
+		{"ftp://ftp.example.net:21/pub/bar/../foo/", "ftp://ftp.example.net/pub/foo/"},
+		{"http://www.example.net", "http://www.example.net/"},
+		{"http://www.example.net/index.php?dir=foo", "http://www.example.net/index.php?dir=foo"},
+	}
+
+	for _, tc := range cases {
+		site, _ := url.Parse(tc.site)
+
+		if normalised := normaliseUrl(site); normalised != tc.expected {
+			t.Fatal("Incorrect normalisation of", tc.site, "got", normalised)
+		}
+	}
+}
+
+func TestListingMemo(t *testing.T) {
+	memo := newListingMemo()
+
+	var calls atomic.Int64
+
+	release := make(chan struct{})
+
+	list := func(ctx context.Context, site *url.URL) (listing, error) {
+		calls.Add(1)
+		<-release
+
+		return listing{files: []*url.URL{site.JoinPath("foo-1.0.tar.gz")}}, nil
+	}
+
+	first, _ := url.Parse("http://www.example.net/pub/")
+	second, _ := url.Parse("HTTP://www.example.net:80/pub/")
+
+	var wg sync.WaitGroup
+
+	results := make([]listing, 10)
+
+	for i := range results {
+		site := first
+
+		if i%2 == 1 {
+			site = second
+		}
+
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			results[i], _ = memo.list(context.Background(), site, list)
+		}()
+	}
+
+	close(release)
+	wg.Wait()
+
+	if calls.Load() != 1 {
+		t.Fatal("Listing fetched more than once:", calls.Load())
+	}
+
+	for _, dir := range results {
+		if len(dir.files) != 1 {
+			t.Fatal("Listing not shared:", dir.files)
+		}
+	}
+
+	if memo.fetched.Load() != 1 || memo.shared.Load() != 9 {
+		t.Fatal("Incorrect counts:", memo.fetched.Load(), memo.shared.Load())
+	}
+}
+
+func TestListingMemoFailure(t *testing.T) {
+	memo := newListingMemo()
+	calls := 0
+
+	list := func(ctx context.Context, site *url.URL) (listing, error) {
+		calls++
+		return listing{}, errors.New("Connection refused")
+	}
+
+	site, _ := url.Parse("http://www.example.net/pub/")
+
+	for range 2 {
+		if _, err := memo.list(context.Background(), site, list); err == nil {
+			t.Fatal("Expected error")
+		}
+	}
+
+	if calls != 2 {
+		t.Fatal("Failure memoised:", calls)
+	}
+}
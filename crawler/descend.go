@@ -0,0 +1,173 @@
+package crawler
+
+import (
+	"context"
+	"log/slog"
+	"maps"
+	"net/url"
+	"path"
+	"slices"
+	"strings"
+	"unicode"
+
+	"github.com/samott/portscout2/types"
+	"github.com/samott/portscout2/version"
+)
+
+// Upper bound on the number of newer version directories crawled
+// for a single job, newest first.
+const maxVersionDirs = 5
+
+// A lister fetches a directory listing.
+type lister func(ctx context.Context, site *url.URL) (listing, error)
+
+/**
+ * A directory listing: its files and subdirectories, and whatever
+ * metadata the listing gave for the files, keyed by URL.
+ */
+type listing struct {
+	files []*url.URL
+	info  map[string]types.FileInfo
+	dirs  []*url.URL
+}
+
+// Adds another listing's files (but not directories) to this one.
+func (l *listing) addFiles(other listing) {
+	l.files = append(l.files, other.files...)
+
+	if len(other.info) == 0 {
+		return
+	}
+
+	if l.info == nil {
+		l.info = make(map[string]types.FileInfo, len(other.info))
+	}
+
+	maps.Copy(l.info, other.info)
+}
+
+/**
+ * Finds the segment of the site path which holds the port's
+ * version, returning its index in the slash-separated path (or
+ * -1 if there isn't one).
+ *
+ * We are looking to see if a major version is embedded in the path,
+ * e.g. http://example.net/4.3/releases/file-4.3.2.zip so that when
+ * we access the URL we can update this instance of the version.
+ * We can handle multiple copies of the version, including different
+ * levels of truncation (4.3.2, 4.3) but we'll make the assumption
+ * that the first instance is the shortest (most "major" number).
+ */
+func getVersionRootFromPath(port types.PortInfo, site *url.URL) int {
+	segments := strings.Split(site.Path, "/")
+
+	for i, segment := range segments {
+		if len(segment) == 0 || !unicode.IsDigit(rune(segment[0])) {
+			continue
+		}
+
+		if strings.ContainsRune(segment, '.') && isVersionPrefix(port.DistVersion, segment) {
+			return i
+		}
+	}
+
+	return -1
+}
+
+// Whether prefix is made of whole components of the version, so
+// that "1.1" is a prefix of "1.1.2" and "1.1-rc1" but not "1.10".
+func isVersionPrefix(version string, prefix string) bool {
+	if !strings.HasPrefix(version, prefix) {
+		return false
+	}
+
+	if len(version) == len(prefix) {
+		return true
+	}
+
+	return strings.ContainsRune(".-/", rune(version[len(prefix)]))
+}
+
+/**
+ * For sites with the version embedded in the path, lists the
+ * parent directory to discover sibling directories for newer
+ * versions, and returns the files found in those. So for
+ * http://example.net/4.3/releases/ we might also crawl
+ * http://example.net/4.4/releases/ and http://example.net/5.0/releases/.
+ *
+ * Failures here aren't fatal, since the main listing has already
+ * succeeded.
+ */
+func (c *Crawler) descend(ctx context.Context, job CrawlJob, list lister) listing {
+	var found listing
+
+	idx := getVersionRootFromPath(job.Port, job.Site)
+
+	if idx < 0 {
+		return found
+	}
+
+	segments := strings.Split(job.Site.Path, "/")
+	current := segments[idx]
+
+	parent := *job.Site
+	parent.Path = strings.Join(segments[:idx], "/") + "/"
+	parent.RawPath = ""
+	parent.RawQuery = ""
+
+	root, err := list(ctx, &parent)
+
+	if err != nil {
+		slog.Debug("Unable to list version root", "site", parent.String(), "err", err)
+		return found
+	}
+
+	newer := make([]string, 0)
+
+	for _, dir := range root.dirs {
+		name := path.Base(dir.Path)
+
+		if !unicode.IsDigit(rune(name[0])) || !version.IsNewer(name, current) {
+			continue
+		}
+
+		newer = append(newer, name)
+	}
+
+	slices.SortFunc(newer, func(a string, b string) int {
+		return version.Compare(b, a)
+	})
+
+	if len(newer) > maxVersionDirs {
+		newer = newer[:maxVersionDirs]
+	}
+
+	for _, name := range newer {
+		// Replace every instance of the version root, so that
+		// e.g. /4.3/src/4.3/ becomes /4.4/src/4.4/
+		replaced := make([]string, len(segments))
+
+		for i, segment := range segments {
+			if segment == current {
+				replaced[i] = name
+			} else {
+				replaced[i] = segment
+			}
+		}
+
+		site := *job.Site
+		site.Path = strings.Join(replaced, "/")
+		site.RawPath = ""
+
+		dir, err := list(ctx, &site)
+
+		if err != nil {
+			slog.Debug("Unable to list version directory", "site", site.String(), "err", err)
+			continue
+		}
+
+		found.addFiles(dir)
+	}
+
+	return found
+}
@@ -0,0 +1,100 @@
+package crawler
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+
+	"github.com/samott/portscout2/types"
+)
+
+func TestGetVersionRootFromPath(t *testing.T) {
+	cases := []struct {
+		version  string
+		path     string
+		expected int
+	}{
+		{"4.3.2", "/pub/foo/4.3/releases/", 3},
+		{"4.3.2", "/pub/foo/4.3.2/", 3},
+		{"4.3.2", "/pub/4/foo/", -1},
+		{"4.3.2", "/pub/foo/releases/", -1},
+		{"4.3.2", "/pub/foo/4.4/", -1},
+		{"1.10.2", "/pub/foo/1.1/", -1},
+		{"1.10.2", "/pub/foo/1.10/", 3},
+		{"1.1-rc1", "/pub/foo/1.1/", 3},
+	}
+
+	for _, test := range cases {
+		port := types.PortInfo{DistVersion: test.version}
+		site := &url.URL{Scheme: "http", Host: "www.example.net", Path: test.path}
+
+		if idx := getVersionRootFromPath(port, site); idx != test.expected {
+			t.Errorf("getVersionRootFromPath(%q, %q) = %d; expected %d", test.version, test.path, idx, test.expected)
+		}
+	}
+}
+
+func TestCrawlHttpDescend(t *testing.T) {
+	listing := func(links ...string) string {
+		doc := "<html><body>"
+
+		for _, link := range links {
+			doc += fmt.Sprintf(`<a href="%s">%s</a>`, link, link)
+		}
+
+		return doc + "</body></html>"
+	}
+
+	pages := map[string]string{
+		"/pub/foo/":              listing("../", "4.2/", "4.3/", "4.4/", "5.0/", "latest/"),
+		"/pub/foo/4.3/releases/": listing("foo-4.3.2.tar.gz"),
+		"/pub/foo/4.4/releases/": listing("foo-4.4.0.tar.gz"),
+	}
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		page, ok := pages[r.URL.Path]
+
+		if !ok {
+			http.NotFound(w, r)
+			return
+		}
+
+		fmt.Fprint(w, page)
+	}))
+	defer server.Close()
+
+	site, _ := url.Parse(server.URL + "/pub/foo/4.3/releases/")
+
+	c := NewCrawler(1)
+
+	job := CrawlJob{
+		Port: types.PortInfo{DistVersion: "4.3.2"},
+		Site: site,
+	}
+
+	var result CrawlResult
+
+	err := c.crawlHttp(context.Background(), job, &result)
+
+	if err != nil {
+		t.Fatal("crawlHttp failed:", err)
+	}
+
+	expected := []string{
+		server.URL + "/pub/foo/4.3/releases/foo-4.3.2.tar.gz",
+		server.URL + "/pub/foo/4.4/releases/foo-4.4.0.tar.gz",
+	}
+
+	if len(result.Files) != len(expected) {
+		t.Fatal("Incorrect file count:", result.Files)
+	}
+
+	for i, file := range result.Files {
+		if file.String() != expected[i] {
+			t.Fatal("Incorrect file:", file)
+		}
+	}
+}
@@ -0,0 +1,182 @@
+package crawler
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"path"
+	"strings"
+
+	"github.com/samott/portscout2/types"
+)
+
+/**
+ * Crawls forge projects with the handler for the port's forge type,
+ * whatever the instance is called, so that e.g. salsa.debian.org is
+ * crawled as GitLab. Sites which aren't the port's forge project,
+ * as for ports without forge information, are crawled over HTTP.
+ */
+type ForgeHandler struct {
+	crawler   *Crawler
+	instances []forgeInstance
+	defaults  map[string]Handler
+}
+
+type forgeInstance struct {
+	forgeType string
+	host      string
+	handler   Handler
+}
+
+/**
+ * Creates a forge handler. Instances which haven't been added with
+ * AddInstance are reached through the API on their own site,
+ * without a token.
+ */
+func NewForgeHandler(c *Crawler) *ForgeHandler {
+	return &ForgeHandler{
+		crawler: c,
+		defaults: map[string]Handler{
+			types.ForgeGitLab: &GitLabHandler{crawler: c},
+			types.ForgeGitea:  &GiteaHandler{crawler: c},
+		},
+	}
+}
+
+/**
+ * Configures the API URL and token for instances of the given type
+ * whose hostname matches hostPattern (using path.Match syntax). The
+ * first matching instance added is used.
+ */
+func (h *ForgeHandler) AddInstance(forgeType string, hostPattern string, apiUrl string, token string) error {
+	var handler Handler
+	var err error
+
+	switch forgeType {
+	case types.ForgeGitLab:
+		handler, err = NewGitLabHandler(h.crawler, apiUrl, token)
+	case types.ForgeGitea:
+		handler, err = NewGiteaHandler(h.crawler, apiUrl, token)
+	default:
+		err = fmt.Errorf("Unknown forge type: %s", forgeType)
+	}
+
+	if err != nil {
+		return err
+	}
+
+	h.instances = append(h.instances, forgeInstance{
+		forgeType: forgeType,
+		host:      strings.ToLower(hostPattern),
+		handler:   handler,
+	})
+
+	return nil
+}
+
+func (h *ForgeHandler) Crawl(ctx context.Context, job CrawlJob, result *CrawlResult) error {
+	forge := job.Port.Forge
+
+	if forge == nil || !onForgeSite(job.Site, forge.Site) {
+		return h.crawler.crawlHttp(ctx, job, result)
+	}
+
+	host := strings.ToLower(job.Site.Hostname())
+
+	for _, instance := range h.instances {
+		if instance.forgeType != forge.Type {
+			continue
+		}
+
+		if ok, _ := path.Match(instance.host, host); ok {
+			return instance.handler.Crawl(ctx, job, result)
+		}
+	}
+
+	if handler, ok := h.defaults[forge.Type]; ok {
+		return handler.Crawl(ctx, job, result)
+	}
+
+	return h.crawler.crawlHttp(ctx, job, result)
+}
+
+// Whether a site is on the forge instance with the given base URL.
+func onForgeSite(site *url.URL, forgeSite string) bool {
+	u, err := url.Parse(forgeSite)
+
+	if err != nil {
+		return false
+	}
+
+	return strings.EqualFold(site.Hostname(), u.Hostname())
+}
+
+// A release as reported by a forge's API.
+type forgeRelease struct {
+	Tag        string
+	Draft      bool
+	Prerelease bool
+}
+
+/**
+ * Turns a forge project's releases and tags into versions, using
+ * the port's tag name as a template (see versionFromTag). Drafts
+ * are skipped, as are prereleases if the port skips betas; their
+ * tags are then skipped too. Each version's file is the archive
+ * generated for its tag.
+ */
+func tagReleases(job CrawlJob, tagName string, releases []forgeRelease, tags []string, archive func(tag string) *url.URL) []Release {
+	seen := make(map[string]bool)
+	found := make([]Release, 0)
+
+	add := func(tag string) {
+		if seen[tag] {
+			return
+		}
+
+		seen[tag] = true
+
+		ver, ok := versionFromTag(tagName, job.Port.DistVersion, tag)
+
+		if !ok {
+			return
+		}
+
+		found = append(found, Release{
+			Version: ver,
+			File:    archive(tag),
+		})
+	}
+
+	for _, release := range releases {
+		if release.Draft || (release.Prerelease && job.Port.Config.SkipBeta) {
+			seen[release.Tag] = true
+			continue
+		}
+
+		add(release.Tag)
+	}
+
+	for _, tag := range tags {
+		add(tag)
+	}
+
+	return found
+}
+
+/**
+ * Returns the API base URL for a forge job: the configured one if
+ * there is one, or else the instance's site with the given path
+ * (e.g. "/api/v4").
+ */
+func forgeApiUrl(apiUrl *url.URL, site *url.URL, apiPath string) *url.URL {
+	if apiUrl != nil {
+		return apiUrl
+	}
+
+	return &url.URL{
+		Scheme: site.Scheme,
+		Host:   site.Host,
+		Path:   apiPath,
+	}
+}
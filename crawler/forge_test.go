@@ -0,0 +1,110 @@
+package crawler
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+
+	"github.com/samott/portscout2/types"
+)
+
+func TestForgeHandler(t *testing.T) {
+	mux := http.NewServeMux()
+
+	mux.HandleFunc("GET /api/v4/projects/{project}/releases", func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, `[{"tag_name": "v1.3.0", "upcoming_release": false}]`)
+	})
+
+	mux.HandleFunc("GET /api/v4/projects/{project}/repository/tags", func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, `[]`)
+	})
+
+	mux.HandleFunc("GET /api/v1/repos/acct/proj/releases", func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, `[{"tag_name": "v1.4.0", "draft": false, "prerelease": false}]`)
+	})
+
+	mux.HandleFunc("GET /api/v1/repos/acct/proj/tags", func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, `[]`)
+	})
+
+	mux.HandleFunc("GET /pub/", func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, `<a href="foo-1.5.0.tar.gz">foo-1.5.0.tar.gz</a>`)
+	})
+
+	server := httptest.NewServer(mux)
+	defer server.Close()
+
+	c := NewCrawler(1)
+
+	h := NewForgeHandler(c)
+
+	// Applies only to GitLab projects on the host
+	err := h.AddInstance(types.ForgeGitLab, "127.0.0.1", server.URL+"/api/v4", "")
+
+	if err != nil {
+		t.Fatal("AddInstance failed:", err)
+	}
+
+	if h.AddInstance("svn", "*", "", "") == nil {
+		t.Fatal("Expected error for unknown forge type")
+	}
+
+	project, _ := url.Parse(server.URL + "/acct/proj")
+	pub, _ := url.Parse(server.URL + "/pub/")
+
+	tests := []struct {
+		forgeType string
+		site      *url.URL
+		version   string
+	}{
+		{forgeType: types.ForgeGitLab, site: project, version: "1.3.0"},
+		// An unconfigured instance, as for salsa.debian.org
+		{forgeType: types.ForgeGitea, site: project, version: "1.4.0"},
+		// No forge information, as for a release download site
+		{forgeType: "", site: pub, version: "1.5.0"},
+	}
+
+	for _, test := range tests {
+		job := CrawlJob{
+			Port: types.PortInfo{
+				DistVersion: "1.2.0",
+			},
+			Site: test.site,
+			File: "foo-1.2.0.tar.gz",
+		}
+
+		if test.forgeType != "" {
+			job.Port.Forge = &types.ForgeInfo{
+				Type:    test.forgeType,
+				Site:    server.URL,
+				Account: "acct",
+				Project: "proj",
+				TagName: "v1.2.0",
+			}
+		}
+
+		var result CrawlResult
+
+		err := h.Crawl(context.Background(), job, &result)
+
+		if err != nil {
+			t.Fatal("Crawl failed:", test.site, err)
+		}
+
+		switch {
+		case len(result.Releases) == 1:
+			if result.Releases[0].Version != test.version {
+				t.Fatal("Unexpected release:", test.site, result.Releases[0].Version)
+			}
+		case len(result.Files) == 1:
+			if result.Files[0].String() != server.URL+"/pub/foo-"+test.version+".tar.gz" {
+				t.Fatal("Unexpected file:", test.site, result.Files[0])
+			}
+		default:
+			t.Fatal("Unexpected result:", test.site, result.Releases, result.Files)
+		}
+	}
+}
@@ -0,0 +1,621 @@
+package crawler
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net"
+	"net/textproto"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/jlaffaye/ftp"
+
+	"github.com/samott/portscout2/types"
+)
+
+// Upper bound on the number of idle connections kept per server.
+const maxIdleFtpConns = 2
+
+type FtpTlsMode int
+
+const (
+	// Plain FTP only
+	FtpTlsOff FtpTlsMode = iota
+	// Explicit FTPS (AUTH TLS) where the server supports it
+	FtpTlsTry
+	// Explicit FTPS, failing if the server doesn't support it
+	FtpTlsRequire
+)
+
+// Accepts "off", "try" or "require"; empty means "try".
+func ParseFtpTlsMode(mode string) (FtpTlsMode, error) {
+	switch mode {
+	case "off":
+		return FtpTlsOff, nil
+	case "", "try":
+		return FtpTlsTry, nil
+	case "require":
+		return FtpTlsRequire, nil
+	default:
+		return FtpTlsOff, fmt.Errorf("Invalid FTP TLS mode: %s", mode)
+	}
+}
+
+/**
+ * Password is sent when logging in anonymously; by convention it
+ * is an email address at which the operator can be reached. Idle
+ * connections are kept for reuse for IdleTimeout, or not at all if
+ * it is zero.
+ */
+type FtpOptions struct {
+	Password    string
+	Tls         FtpTlsMode
+	IdleTimeout time.Duration
+}
+
+type ftpPool struct {
+	mu     sync.Mutex
+	idle   map[string][]idleFtpConn
+	noMlsd map[string]bool
+	noTls  map[string]bool
+}
+
+type idleFtpConn struct {
+	conn    *ftp.ServerConn
+	control net.Conn
+	since   time.Time
+}
+
+/**
+ * A connection handed out by dialFtp. It holds a limiter slot, and
+ * must be given back with Close, which returns it to the pool for
+ * reuse (or quits, if it may be broken).
+ *
+ * The library only uses a context when dialling, so the control
+ * connection is closed if the job's context is cancelled while the
+ * connection is in use; stop undoes that.
+ */
+type ftpConn struct {
+	*ftp.ServerConn
+	crawler *Crawler
+	key     string
+	control net.Conn
+	stop    func() bool
+	release func()
+	broken  bool
+}
+
+/**
+ * A connection which times out if the server doesn't respond to
+ * (or accept) each read or write within the timeout, so that a
+ * stalled server can't hold up a worker indefinitely.
+ */
+type deadlineConn struct {
+	net.Conn
+	timeout time.Duration
+}
+
+func (d *deadlineConn) Read(b []byte) (int, error) {
+	d.Conn.SetReadDeadline(time.Now().Add(d.timeout))
+
+	return d.Conn.Read(b)
+}
+
+func (d *deadlineConn) Write(b []byte) (int, error) {
+	d.Conn.SetWriteDeadline(time.Now().Add(d.timeout))
+
+	return d.Conn.Write(b)
+}
+
+func newFtpPool() *ftpPool {
+	return &ftpPool{
+		idle:   make(map[string][]idleFtpConn),
+		noMlsd: make(map[string]bool),
+		noTls:  make(map[string]bool),
+	}
+}
+
+func (c *Crawler) SetFtpOptions(options FtpOptions) {
+	c.ftpOptions = options
+}
+
+func (c *Crawler) crawlFtp(ctx context.Context, job CrawlJob, result *CrawlResult) error {
+	list := c.shared(c.listFtp)
+
+	dir, err := list(ctx, job.Site)
+
+	if err == nil {
+		dir.addFiles(c.descend(ctx, job, list))
+		result.addListing(dir)
+	}
+
+	err = c.guessIfNeeded(ctx, job, result, err, c.probeFtp)
+
+	result.stripUserinfo()
+
+	return err
+}
+
+/**
+ * Removes credentials from the file URLs, and those of releases
+ * found by guessing, which are reported (and stored) as download
+ * locations. Listings keep them, since they're needed to descend
+ * into subdirectories.
+ */
+func (r *CrawlResult) stripUserinfo() {
+	for i, file := range r.Files {
+		if file.User == nil {
+			continue
+		}
+
+		stripped := *file
+		stripped.User = nil
+
+		if info, ok := r.FileInfo[file.String()]; ok {
+			delete(r.FileInfo, file.String())
+			r.FileInfo[stripped.String()] = info
+		}
+
+		r.Files[i] = &stripped
+	}
+
+	for i, release := range r.Releases {
+		if release.File == nil || release.File.User == nil {
+			continue
+		}
+
+		stripped := *release.File
+		stripped.User = nil
+
+		r.Releases[i].File = &stripped
+	}
+}
+
+/**
+ * Lists a directory, using MLSD where the server supports it since
+ * its output is standardised, unlike that of LIST. Some servers
+ * advertise MLST but then refuse MLSD, in which case we reconnect
+ * and stick to LIST for that server.
+ *
+ * Symbolic links (which only LIST reveals) might point at either a
+ * file or a directory, so they're reported as both; links to files
+ * with version numbers in their names are common ("foo-latest.tar.gz"),
+ * as are links to version directories.
+ */
+func (c *Crawler) listFtp(ctx context.Context, site *url.URL) (listing, error) {
+	dir, err := c.listFtpOnce(ctx, site)
+
+	var listErr *ftpListError
+	var protoErr *textproto.Error
+
+	if errors.As(err, &listErr) && listErr.mlsd && errors.As(err, &protoErr) &&
+		protoErr.Code >= 500 && protoErr.Code <= 504 && c.disableMlsd(site) {
+		slog.Debug("MLSD refused; falling back to LIST", "site", site.String(), "err", err)
+		return c.listFtpOnce(ctx, site)
+	}
+
+	return dir, err
+}
+
+func (c *Crawler) listFtpOnce(ctx context.Context, site *url.URL) (listing, error) {
+	dir := listing{
+		files: make([]*url.URL, 0),
+		info:  make(map[string]types.FileInfo),
+		dirs:  make([]*url.URL, 0),
+	}
+
+	client, err := c.dialFtp(ctx, site)
+
+	if err != nil {
+		return listing{}, err
+	}
+
+	defer client.Close()
+
+	cwd := site.Path
+
+	if cwd == "" {
+		cwd = "/"
+	}
+
+	err = client.ChangeDir(cwd)
+
+	if err != nil {
+		client.check(err)
+		return listing{}, fmt.Errorf("FTP cwd failed: %w", err)
+	}
+
+	entries, err := client.List(".")
+
+	if err != nil {
+		client.check(err)
+		return listing{}, fmt.Errorf("FTP list failed: %w", &ftpListError{err, client.IsTimePreciseInList()})
+	}
+
+	for _, entry := range entries {
+		if entry.Name == "." || entry.Name == ".." || strings.Contains(entry.Name, "/") {
+			continue
+		}
+
+		switch entry.Type {
+		case ftp.EntryTypeFile:
+			file := site.JoinPath(entry.Name)
+
+			dir.files = append(dir.files, file)
+			dir.info[file.String()] = types.FileInfo{
+				Size:    int64(entry.Size),
+				ModTime: entry.Time,
+			}
+		case ftp.EntryTypeFolder:
+			dir.dirs = append(dir.dirs, site.JoinPath(entry.Name, "/"))
+		case ftp.EntryTypeLink:
+			// The size and time are the link's own
+			dir.files = append(dir.files, site.JoinPath(entry.Name))
+			dir.dirs = append(dir.dirs, site.JoinPath(entry.Name, "/"))
+		}
+	}
+
+	return dir, nil
+}
+
+/**
+ * Wraps a failed listing, recording whether it was made with MLSD
+ * so that listFtp knows whether falling back to LIST might help.
+ */
+type ftpListError struct {
+	err  error
+	mlsd bool
+}
+
+func (e *ftpListError) Error() string {
+	return e.err.Error()
+}
+
+func (e *ftpListError) Unwrap() error {
+	return e.err
+}
+
+// Stops using MLSD for a server, returning false if it was already.
+func (c *Crawler) disableMlsd(site *url.URL) bool {
+	key := ftpKey(site)
+
+	c.ftpPool.mu.Lock()
+	defer c.ftpPool.mu.Unlock()
+
+	if c.ftpPool.noMlsd[key] {
+		return false
+	}
+
+	c.ftpPool.noMlsd[key] = true
+
+	// Pooled connections still have MLSD enabled
+	for _, idle := range c.ftpPool.idle[key] {
+		idle.conn.Quit()
+	}
+
+	delete(c.ftpPool.idle, key)
+
+	return true
+}
+
+/**
+ * Returns a logged in connection to the FTP server for the given
+ * site, reusing an idle one if possible. The connection must be
+ * given back with Close.
+ */
+func (c *Crawler) dialFtp(ctx context.Context, site *url.URL) (*ftpConn, error) {
+	release, err := c.acquire(ctx, site)
+
+	if err != nil {
+		return nil, err
+	}
+
+	key := ftpKey(site)
+	start := time.Now()
+
+	conn, control := c.idleFtpConn(ctx, key)
+
+	if conn == nil {
+		conn, control, err = c.loginFtp(ctx, site, key)
+	}
+
+	if err != nil {
+		class, _ := classifyError(err)
+		release(time.Since(start), class == ErrorThrottled)()
+		return nil, err
+	}
+
+	return &ftpConn{
+		ServerConn: conn,
+		crawler:    c,
+		key:        key,
+		control:    control,
+		stop: context.AfterFunc(ctx, func() {
+			control.Close()
+		}),
+		release: release(time.Since(start), false),
+	}, nil
+}
+
+/**
+ * Connects and logs in. Every read and write on the connection,
+ * and on its data connections, is subject to the request timeout;
+ * the control connection is returned too so that it can be closed
+ * when a job is cancelled.
+ */
+func (c *Crawler) loginFtp(ctx context.Context, site *url.URL, key string) (*ftp.ServerConn, net.Conn, error) {
+	// For some reason the library doesn't use the default
+	// FTP port if none is provided in the URL
+	addr := site.Host
+
+	if site.Port() == "" {
+		addr = site.Hostname() + ":21"
+	}
+
+	c.ftpPool.mu.Lock()
+	useTls := c.ftpOptions.Tls == FtpTlsRequire || (c.ftpOptions.Tls == FtpTlsTry && !c.ftpPool.noTls[key])
+	noMlsd := c.ftpPool.noMlsd[key]
+	c.ftpPool.mu.Unlock()
+
+	tlsConfig := &tls.Config{
+		ServerName: site.Hostname(),
+	}
+
+	dialer := net.Dialer{
+		Timeout: c.requestTimeout,
+	}
+
+	var control net.Conn
+	stop := func() bool { return true }
+
+	// Given a dial function, the library no longer sets up TLS on
+	// data connections itself
+	dial := func(network string, address string) (net.Conn, error) {
+		if control != nil {
+			conn, err := dialer.Dial(network, address)
+
+			if err != nil {
+				return nil, err
+			}
+
+			conn = &deadlineConn{conn, c.requestTimeout}
+
+			if useTls {
+				return tls.Client(conn, tlsConfig), nil
+			}
+
+			return conn, nil
+		}
+
+		conn, err := dialer.DialContext(ctx, network, address)
+
+		if err != nil {
+			return nil, err
+		}
+
+		control = &deadlineConn{conn, c.requestTimeout}
+		stop = context.AfterFunc(ctx, func() {
+			control.Close()
+		})
+
+		return control, nil
+	}
+
+	options := []ftp.DialOption{
+		ftp.DialWithDialFunc(dial),
+		ftp.DialWithDisabledMLSD(noMlsd),
+	}
+
+	if useTls {
+		options = append(options, ftp.DialWithExplicitTLS(tlsConfig))
+	}
+
+	conn, err := ftp.Dial(addr, options...)
+
+	var protoErr *textproto.Error
+
+	if err != nil && useTls && c.ftpOptions.Tls == FtpTlsTry && errors.As(err, &protoErr) && protoErr.Code >= 500 {
+		stop()
+
+		// AUTH TLS refused; carry on in the clear
+		c.ftpPool.mu.Lock()
+		c.ftpPool.noTls[key] = true
+		c.ftpPool.mu.Unlock()
+
+		return c.loginFtp(ctx, site, key)
+	}
+
+	if err != nil {
+		stop()
+		return nil, nil, fmt.Errorf("FTP dial failed: %w", err)
+	}
+
+	err = c.loginFtpUser(conn, site)
+
+	if err != nil && useTls && c.ftpOptions.Tls == FtpTlsTry && isTlsError(err) {
+		stop()
+
+		// The handshake happens on the first command after AUTH
+		// TLS; a bad certificate is no worse than plain FTP
+		slog.Debug("FTP TLS handshake failed; falling back to plain FTP", "site", site.Host, "err", err)
+
+		c.ftpPool.mu.Lock()
+		c.ftpPool.noTls[key] = true
+		c.ftpPool.mu.Unlock()
+
+		return c.loginFtp(ctx, site, key)
+	}
+
+	if !stop() && err == nil {
+		conn.Quit()
+		err = fmt.Errorf("FTP login failed: %w", ctx.Err())
+	}
+
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return conn, control, nil
+}
+
+func (c *Crawler) loginFtpUser(conn *ftp.ServerConn, site *url.URL) error {
+	user := "anonymous"
+	password := c.ftpOptions.Password
+
+	if password == "" {
+		password = "anonymous@"
+	}
+
+	if site.User != nil {
+		user = site.User.Username()
+
+		if p, ok := site.User.Password(); ok {
+			password = p
+		}
+	}
+
+	err := conn.Login(user, password)
+
+	if err != nil {
+		conn.Quit()
+		return fmt.Errorf("FTP login failed: %w", err)
+	}
+
+	return nil
+}
+
+/**
+ * Takes an idle connection from the pool, checking it's still
+ * alive, and returns it along with its control connection.
+ */
+func (c *Crawler) idleFtpConn(ctx context.Context, key string) (*ftp.ServerConn, net.Conn) {
+	for {
+		c.ftpPool.mu.Lock()
+
+		idle := c.ftpPool.idle[key]
+
+		if len(idle) == 0 {
+			c.ftpPool.mu.Unlock()
+			return nil, nil
+		}
+
+		candidate := idle[len(idle)-1]
+		c.ftpPool.idle[key] = idle[:len(idle)-1]
+
+		c.ftpPool.mu.Unlock()
+
+		if time.Since(candidate.since) < c.ftpOptions.IdleTimeout {
+			stop := context.AfterFunc(ctx, func() {
+				candidate.control.Close()
+			})
+
+			err := candidate.conn.NoOp()
+
+			if stop() && err == nil {
+				return candidate.conn, candidate.control
+			}
+		}
+
+		candidate.conn.Quit()
+	}
+}
+
+/**
+ * Notes an error from an operation on the connection. Anything
+ * other than an error reply from the server (e.g. a 550 for a
+ * missing directory) means the connection is in an unknown state
+ * and mustn't be reused.
+ */
+func (f *ftpConn) check(err error) {
+	var protoErr *textproto.Error
+
+	if err != nil && !errors.As(err, &protoErr) {
+		f.broken = true
+	}
+}
+
+// Returns the connection to the pool, or quits if it can't be reused.
+func (f *ftpConn) Close() {
+	defer f.release()
+
+	if !f.stop() {
+		// Closed on cancellation
+		f.broken = true
+	}
+
+	c := f.crawler
+
+	if f.broken || c.ftpOptions.IdleTimeout <= 0 {
+		f.Quit()
+		return
+	}
+
+	c.ftpPool.mu.Lock()
+
+	idle := c.ftpPool.idle[f.key]
+
+	if len(idle) >= maxIdleFtpConns {
+		c.ftpPool.mu.Unlock()
+		f.Quit()
+		return
+	}
+
+	c.ftpPool.idle[f.key] = append(idle, idleFtpConn{
+		conn:    f.ServerConn,
+		control: f.control,
+		since:   time.Now(),
+	})
+
+	c.ftpPool.mu.Unlock()
+}
+
+// Quits all idle connections.
+func (c *Crawler) closeIdleFtp() {
+	c.ftpPool.mu.Lock()
+	defer c.ftpPool.mu.Unlock()
+
+	for key, idle := range c.ftpPool.idle {
+		for _, conn := range idle {
+			conn.conn.Quit()
+		}
+
+		delete(c.ftpPool.idle, key)
+	}
+}
+
+// Connections can only be shared between sites with the same
+// server and credentials.
+func ftpKey(site *url.URL) string {
+	key := strings.ToLower(site.Host)
+
+	if site.User != nil {
+		key = site.User.String() + "@" + key
+	}
+
+	return key
+}
+
+func isTlsError(err error) bool {
+	var certErr *tls.CertificateVerificationError
+	var recordErr tls.RecordHeaderError
+	var alertErr tls.AlertError
+	var unknownAuthority x509.UnknownAuthorityError
+	var hostnameErr x509.HostnameError
+	var invalidErr x509.CertificateInvalidError
+
+	return errors.As(err, &certErr) || errors.As(err, &recordErr) || errors.As(err, &alertErr) ||
+		errors.As(err, &unknownAuthority) || errors.As(err, &hostnameErr) || errors.As(err, &invalidErr) ||
+		strings.Contains(err.Error(), "tls: ")
+}
+
+func isFtpPermanentError(err error) bool {
+	var protoErr *textproto.Error
+
+	return errors.As(err, &protoErr) && protoErr.Code >= 500
+}
@@ -0,0 +1,583 @@
+package crawler
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"net"
+	"net/url"
+	"path"
+	"slices"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/samott/portscout2/types"
+)
+
+type fakeFtpEntry struct {
+	name string
+	kind string
+}
+
+/**
+ * A minimal anonymous FTP server: just enough of the protocol for
+ * the client library to log in, change directory and list, and to
+ * probe for files (which needn't be listed) by their size.
+ */
+type fakeFtpServer struct {
+	listener   net.Listener
+	dirs       map[string][]fakeFtpEntry
+	files      map[string]bool
+	mlst       bool
+	brokenMlsd bool
+	authTls    bool
+	stall      bool
+
+	mu        sync.Mutex
+	passwords []string
+	commands  map[string]int
+}
+
+func newFakeFtpServer(t *testing.T, dirs map[string][]fakeFtpEntry) *fakeFtpServer {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+
+	if err != nil {
+		t.Fatal("Listen failed:", err)
+	}
+
+	s := &fakeFtpServer{
+		listener: listener,
+		dirs:     dirs,
+		commands: make(map[string]int),
+	}
+
+	go func() {
+		for {
+			conn, err := listener.Accept()
+
+			if err != nil {
+				return
+			}
+
+			go s.serve(conn)
+		}
+	}()
+
+	t.Cleanup(func() {
+		listener.Close()
+	})
+
+	return s
+}
+
+func (s *fakeFtpServer) url(dir string) *url.URL {
+	site, _ := url.Parse("ftp://" + s.listener.Addr().String() + dir)
+
+	return site
+}
+
+func (s *fakeFtpServer) count(command string) int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.commands[command]
+}
+
+func (s *fakeFtpServer) password(i int) string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if i >= len(s.passwords) {
+		return ""
+	}
+
+	return s.passwords[i]
+}
+
+func (s *fakeFtpServer) serve(conn net.Conn) {
+	defer conn.Close()
+
+	r := bufio.NewReader(conn)
+	reply := func(format string, args ...any) {
+		fmt.Fprintf(conn, format+"\r\n", args...)
+	}
+
+	var data net.Listener
+	cwd := "/"
+
+	reply("220 Fake FTP")
+
+	for {
+		line, err := r.ReadString('\n')
+
+		if err != nil {
+			return
+		}
+
+		command, arg, _ := strings.Cut(strings.TrimSpace(line), " ")
+		command = strings.ToUpper(command)
+
+		s.mu.Lock()
+		s.commands[command]++
+		s.mu.Unlock()
+
+		switch command {
+		case "AUTH":
+			if s.authTls {
+				// Then answer the handshake in plain text, as
+				// a broken TLS setup might
+				reply("234 Go ahead")
+				reply("500 Not really")
+			} else {
+				reply("502 Not implemented")
+			}
+		case "USER":
+			reply("331 Password required")
+		case "PASS":
+			s.mu.Lock()
+			s.passwords = append(s.passwords, arg)
+			s.mu.Unlock()
+			reply("230 Logged in")
+		case "FEAT":
+			if s.mlst {
+				reply("211-Features:\r\n MLST type*;size*;\r\n UTF8\r\n211 End")
+			} else {
+				reply("211-Features:\r\n UTF8\r\n211 End")
+			}
+		case "TYPE", "OPTS", "NOOP":
+			reply("200 OK")
+		case "CWD":
+			if s.stall {
+				// Hang until the client gives up
+				io.Copy(io.Discard, r)
+				return
+			}
+
+			dir := arg
+
+			if !path.IsAbs(dir) {
+				dir = path.Join(cwd, dir)
+			}
+
+			dir = path.Clean(dir)
+
+			if _, ok := s.dirs[dir]; !ok {
+				reply("550 No such directory")
+				continue
+			}
+
+			cwd = dir
+			reply("250 OK")
+		case "SIZE":
+			file := arg
+
+			if !path.IsAbs(file) {
+				file = path.Join(cwd, file)
+			}
+
+			if !s.files[path.Clean(file)] {
+				reply("550 No such file")
+				continue
+			}
+
+			reply("213 1")
+		case "EPSV":
+			data, err = net.Listen("tcp", "127.0.0.1:0")
+
+			if err != nil {
+				reply("425 Can't open data connection")
+				continue
+			}
+
+			reply("229 Entering Extended Passive Mode (|||%d|)", data.Addr().(*net.TCPAddr).Port)
+		case "MLSD", "LIST":
+			if command == "MLSD" && s.brokenMlsd {
+				data.Close()
+				reply("500 Unknown command")
+				continue
+			}
+
+			dataConn, err := data.Accept()
+			data.Close()
+
+			if err != nil {
+				return
+			}
+
+			reply("150 Here comes the listing")
+
+			for _, entry := range s.dirs[cwd] {
+				if command == "MLSD" {
+					fmt.Fprintf(dataConn, "type=%s;size=1; %s\r\n", entry.kind, entry.name)
+					continue
+				}
+
+				switch entry.kind {
+				case "dir":
+					fmt.Fprintf(dataConn, "drwxr-xr-x 2 ftp ftp 4096 Jan 01 2024 %s\r\n", entry.name)
+				case "link":
+					fmt.Fprintf(dataConn, "lrwxrwxrwx 1 ftp ftp 6 Jan 01 2024 %s -> target\r\n", entry.name)
+				default:
+					fmt.Fprintf(dataConn, "-rw-r--r-- 1 ftp ftp 1 Jan 01 2024 %s\r\n", entry.name)
+				}
+			}
+
+			dataConn.Close()
+			reply("226 Done")
+		case "QUIT":
+			reply("221 Bye")
+			return
+		default:
+			reply("502 Not implemented")
+		}
+	}
+}
+
+func ftpNames(site *url.URL, urls []*url.URL) []string {
+	names := make([]string, 0, len(urls))
+
+	for _, u := range urls {
+		names = append(names, strings.TrimPrefix(u.String(), site.String()))
+	}
+
+	slices.Sort(names)
+
+	return names
+}
+
+func TestFtpList(t *testing.T) {
+	server := newFakeFtpServer(t, map[string][]fakeFtpEntry{
+		"/": {},
+		"/pub/foo": {
+			{"foo-1.0.tar.gz", "file"},
+			{"foo 1.1.tar.gz", "file"},
+			{"old", "dir"},
+			{".", "cdir"},
+			{"..", "pdir"},
+		},
+	})
+	server.mlst = true
+
+	c := NewCrawler(1)
+	c.SetFtpOptions(FtpOptions{
+		Password:    "ports@example.org",
+		Tls:         FtpTlsTry,
+		IdleTimeout: time.Minute,
+	})
+
+	site := server.url("/pub/foo/")
+
+	for range 2 {
+		dir, err := c.listFtp(context.Background(), site)
+
+		if err != nil {
+			t.Fatal("listFtp failed:", err)
+		}
+
+		if names := ftpNames(site, dir.files); !slices.Equal(names, []string{"foo%201.1.tar.gz", "foo-1.0.tar.gz"}) {
+			t.Fatal("Unexpected files:", names)
+		}
+
+		if names := ftpNames(site, dir.dirs); !slices.Equal(names, []string{"old/"}) {
+			t.Fatal("Unexpected dirs:", names)
+		}
+
+		if info := dir.info[site.String()+"foo-1.0.tar.gz"]; info.Size != 1 {
+			t.Fatal("Expected size from MLSD; got:", info.Size)
+		}
+	}
+
+	// A missing directory doesn't spoil the connection
+	_, err := c.listFtp(context.Background(), server.url("/pub/bar/"))
+
+	if err == nil {
+		t.Fatal("Expected error listing missing directory")
+	}
+
+	if class, _ := classifyError(err); class != ErrorPermanent {
+		t.Fatal("Expected missing directory to be a permanent error")
+	}
+
+	_, err = c.listFtp(context.Background(), site)
+
+	if err != nil {
+		t.Fatal("listFtp failed:", err)
+	}
+
+	c.closeIdleFtp()
+
+	if server.count("PASS") != 1 {
+		t.Fatal("Expected connection to be reused; logins:", server.count("PASS"))
+	}
+
+	if server.password(0) != "ports@example.org" {
+		t.Fatal("Unexpected password:", server.password(0))
+	}
+
+	if server.count("MLSD") != 3 || server.count("LIST") != 0 {
+		t.Fatal("Expected MLSD to be used")
+	}
+
+	if server.count("AUTH") != 1 {
+		t.Fatal("Expected a single AUTH TLS attempt")
+	}
+
+	// One for the connection which tried AUTH TLS; the server
+	// sees the other asynchronously
+	for i := 0; i < 100 && server.count("QUIT") < 2; i++ {
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	if server.count("QUIT") != 2 {
+		t.Fatal("Expected idle connection to be closed")
+	}
+}
+
+func TestFtpListFallback(t *testing.T) {
+	server := newFakeFtpServer(t, map[string][]fakeFtpEntry{
+		"/": {
+			{"foo-1.0.tar.gz", "file"},
+			{"latest", "link"},
+			{"v1.1", "dir"},
+		},
+	})
+	server.mlst = true
+	server.brokenMlsd = true
+
+	c := NewCrawler(1)
+	c.SetFtpOptions(FtpOptions{
+		Tls: FtpTlsOff,
+	})
+
+	site := server.url("")
+
+	for range 2 {
+		dir, err := c.listFtp(context.Background(), site)
+
+		if err != nil {
+			t.Fatal("listFtp failed:", err)
+		}
+
+		if names := ftpNames(site, dir.files); !slices.Equal(names, []string{"/foo-1.0.tar.gz", "/latest"}) {
+			t.Fatal("Unexpected files:", names)
+		}
+
+		if names := ftpNames(site, dir.dirs); !slices.Equal(names, []string{"/latest/", "/v1.1/"}) {
+			t.Fatal("Unexpected dirs:", names)
+		}
+	}
+
+	if server.count("MLSD") != 1 {
+		t.Fatal("Expected MLSD to be tried only once; tried:", server.count("MLSD"))
+	}
+
+	if server.count("AUTH") != 0 {
+		t.Fatal("Expected no AUTH TLS with TLS off")
+	}
+
+	if server.password(0) != "anonymous@" {
+		t.Fatal("Unexpected default password:", server.password(0))
+	}
+
+	// Pooling disabled, so every listing logs in afresh
+	if server.count("PASS") != 3 {
+		t.Fatal("Expected 3 logins; got:", server.count("PASS"))
+	}
+}
+
+func TestFtpTlsRequired(t *testing.T) {
+	server := newFakeFtpServer(t, map[string][]fakeFtpEntry{
+		"/": {},
+	})
+
+	c := NewCrawler(1)
+	c.SetFtpOptions(FtpOptions{
+		Tls: FtpTlsRequire,
+	})
+
+	_, err := c.listFtp(context.Background(), server.url("/"))
+
+	if err == nil {
+		t.Fatal("Expected error from server without TLS")
+	}
+
+	if server.count("PASS") != 0 {
+		t.Fatal("Expected no login in the clear")
+	}
+}
+
+func TestFtpStalledServer(t *testing.T) {
+	server := newFakeFtpServer(t, map[string][]fakeFtpEntry{
+		"/": {},
+	})
+	server.stall = true
+
+	c := NewCrawler(1)
+	c.SetTimeouts(200*time.Millisecond, 0)
+	c.SetFtpOptions(FtpOptions{
+		Tls:         FtpTlsOff,
+		IdleTimeout: time.Minute,
+	})
+
+	start := time.Now()
+
+	_, err := c.listFtp(context.Background(), server.url("/"))
+
+	if err == nil {
+		t.Fatal("Expected error from stalled server")
+	}
+
+	if time.Since(start) > 2*time.Second {
+		t.Fatal("Request timeout not applied; took", time.Since(start))
+	}
+
+	// Cancellation doesn't wait for the request timeout
+	c.SetTimeouts(time.Hour, 0)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 200*time.Millisecond)
+	defer cancel()
+
+	start = time.Now()
+
+	_, err = c.listFtp(ctx, server.url("/"))
+
+	if err == nil {
+		t.Fatal("Expected error from stalled server")
+	}
+
+	if time.Since(start) > 2*time.Second {
+		t.Fatal("Cancellation ignored; took", time.Since(start))
+	}
+
+	c.closeIdleFtp()
+
+	if server.count("PASS") != 2 {
+		t.Fatal("Expected broken connections not to be reused; logins:", server.count("PASS"))
+	}
+}
+
+func TestFtpTlsFallback(t *testing.T) {
+	server := newFakeFtpServer(t, map[string][]fakeFtpEntry{
+		"/": {
+			{"foo-1.0.tar.gz", "file"},
+		},
+	})
+	server.authTls = true
+
+	c := NewCrawler(1)
+	c.SetFtpOptions(FtpOptions{
+		Tls: FtpTlsTry,
+	})
+
+	site := server.url("/")
+	site.User = url.UserPassword("user", "secret")
+
+	job := CrawlJob{
+		Site: site,
+	}
+
+	var result CrawlResult
+
+	err := c.crawlFtp(context.Background(), job, &result)
+
+	if err != nil {
+		t.Fatal("crawlFtp failed:", err)
+	}
+
+	if server.password(0) != "secret" {
+		t.Fatal("Expected login in the clear after failed handshake")
+	}
+
+	if len(result.Files) != 1 || result.Files[0].User != nil {
+		t.Fatal("Expected file without credentials:", result.Files)
+	}
+
+	if _, ok := result.FileInfo[result.Files[0].String()]; !ok {
+		t.Fatal("Expected file info under the stripped URL")
+	}
+
+	c.SetFtpOptions(FtpOptions{
+		Tls: FtpTlsRequire,
+	})
+
+	if _, err := c.listFtp(context.Background(), server.url("/")); err == nil {
+		t.Fatal("Expected failed handshake to be an error when TLS is required")
+	}
+}
+
+func TestFtpGuessStripsUserinfo(t *testing.T) {
+	server := newFakeFtpServer(t, map[string][]fakeFtpEntry{
+		"/dl": {
+			{"README", "file"},
+		},
+	})
+	server.files = map[string]bool{
+		"/dl/foo-1.2.4.tar.gz": true,
+	}
+
+	c := NewCrawler(1)
+	c.SetGuess(true)
+
+	site := server.url("/dl/")
+	site.User = url.UserPassword("user", "secret")
+
+	job := CrawlJob{
+		Port: types.PortInfo{
+			DistName:    "foo-1.2.3",
+			DistVersion: "1.2.3",
+			Config: types.PortConfig{
+				LimitWhich: -1,
+			},
+		},
+		Site: site,
+		File: "foo-1.2.3.tar.gz",
+	}
+
+	var result CrawlResult
+
+	err := c.crawlFtp(context.Background(), job, &result)
+
+	if err != nil {
+		t.Fatal("crawlFtp failed:", err)
+	}
+
+	if len(result.Releases) != 1 || result.Releases[0].Version != "1.2.4" {
+		t.Fatal("Version not guessed:", result.Releases)
+	}
+
+	for _, file := range result.Files {
+		if file.User != nil {
+			t.Fatal("Expected file without credentials:", file)
+		}
+	}
+
+	for _, release := range result.Releases {
+		if release.File.User != nil {
+			t.Fatal("Expected release without credentials:", release.File)
+		}
+	}
+}
+
+func TestParseFtpTlsMode(t *testing.T) {
+	tests := map[string]FtpTlsMode{
+		"":        FtpTlsTry,
+		"off":     FtpTlsOff,
+		"try":     FtpTlsTry,
+		"require": FtpTlsRequire,
+	}
+
+	for input, expected := range tests {
+		mode, err := ParseFtpTlsMode(input)
+
+		if err != nil || mode != expected {
+			t.Fatal("Unexpected mode for", input, mode, err)
+		}
+	}
+
+	if _, err := ParseFtpTlsMode("sometimes"); err == nil {
+		t.Fatal("Expected error for invalid mode")
+	}
+}
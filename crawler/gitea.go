@@ -0,0 +1,123 @@
+package crawler
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+
+	"github.com/samott/portscout2/types"
+)
+
+const giteaMaxPages = 10
+
+// Also serves Forgejo (e.g. Codeberg), which shares Gitea's API.
+type GiteaHandler struct {
+	crawler *Crawler
+	apiUrl  *url.URL
+	token   string
+}
+
+type giteaRelease struct {
+	TagName    string `json:"tag_name"`
+	Draft      bool   `json:"draft"`
+	Prerelease bool   `json:"prerelease"`
+}
+
+type giteaTag struct {
+	Name string `json:"name"`
+}
+
+/**
+ * Creates a handler which lists the releases and tags of Gitea and
+ * Forgejo projects through the REST API at apiUrl, or if that's
+ * empty at /api/v1 on the project's instance. The token is
+ * optional.
+ */
+func NewGiteaHandler(c *Crawler, apiUrl string, token string) (*GiteaHandler, error) {
+	h := &GiteaHandler{
+		crawler: c,
+		token:   token,
+	}
+
+	if apiUrl != "" {
+		u, err := url.Parse(apiUrl)
+
+		if err != nil {
+			return nil, fmt.Errorf("Invalid Gitea API URL: %w", err)
+		}
+
+		h.apiUrl = u
+	}
+
+	return h, nil
+}
+
+func (h *GiteaHandler) Crawl(ctx context.Context, job CrawlJob, result *CrawlResult) error {
+	forge := job.Port.Forge
+
+	if forge == nil {
+		return h.crawler.crawlHttp(ctx, job, result)
+	}
+
+	if forge.Type != types.ForgeGitea {
+		return errors.New("Port has no Gitea information")
+	}
+
+	gtReleases, err := giteaFetchAll[giteaRelease](ctx, h, job, "releases")
+
+	if err != nil {
+		return err
+	}
+
+	gtTags, err := giteaFetchAll[giteaTag](ctx, h, job, "tags")
+
+	if err != nil {
+		return err
+	}
+
+	releases := make([]forgeRelease, 0, len(gtReleases))
+
+	for _, release := range gtReleases {
+		releases = append(releases, forgeRelease{
+			Tag:        release.TagName,
+			Draft:      release.Draft,
+			Prerelease: release.Prerelease,
+		})
+	}
+
+	tags := make([]string, 0, len(gtTags))
+
+	for _, tag := range gtTags {
+		tags = append(tags, tag.Name)
+	}
+
+	result.Releases = tagReleases(job, forge.TagName, releases, tags, func(tag string) *url.URL {
+		return job.Site.JoinPath("archive", tag+".tar.gz")
+	})
+
+	return nil
+}
+
+// Fetches every page of a repository collection ("releases" or "tags").
+func giteaFetchAll[T any](ctx context.Context, h *GiteaHandler, job CrawlJob, collection string) ([]T, error) {
+	forge := job.Port.Forge
+
+	u := forgeApiUrl(h.apiUrl, job.Site, "/api/v1").JoinPath("repos", forge.Account, forge.Project, collection)
+	u.RawQuery = "limit=50"
+
+	header := make(http.Header)
+
+	if h.token != "" {
+		header.Set("Authorization", "token "+h.token)
+	}
+
+	items, err := fetchAllPages[T](ctx, h.crawler, u, header, giteaMaxPages)
+
+	if err != nil {
+		return nil, fmt.Errorf("Gitea %s query failed: %w", collection, err)
+	}
+
+	return items, nil
+}
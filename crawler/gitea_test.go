@@ -0,0 +1,130 @@
+package crawler
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/samott/portscout2/types"
+)
+
+func TestGiteaHandler(t *testing.T) {
+	mux := http.NewServeMux()
+
+	mux.HandleFunc("GET /api/v1/repos/acct/proj/releases", func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "token secret" {
+			http.Error(w, "unauthorised", http.StatusUnauthorized)
+			return
+		}
+
+		fmt.Fprint(w, `[
+			{"tag_name": "v2.1.0", "draft": true, "prerelease": false},
+			{"tag_name": "v2.0.0-rc1", "draft": false, "prerelease": true},
+			{"tag_name": "v1.9.0", "draft": false, "prerelease": false}
+		]`)
+	})
+
+	mux.HandleFunc("GET /api/v1/repos/acct/proj/tags", func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, `[
+			{"name": "v2.1.0"},
+			{"name": "v2.0.0-rc1"},
+			{"name": "v1.9.1"}
+		]`)
+	})
+
+	mux.HandleFunc("GET /api/v1/repos/acct/empty/{collection}", func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, `[]`)
+	})
+
+	server := httptest.NewServer(mux)
+	defer server.Close()
+
+	c := NewCrawler(1)
+
+	h, err := NewGiteaHandler(c, "", "secret")
+
+	if err != nil {
+		t.Fatal("NewGiteaHandler failed:", err)
+	}
+
+	// With no API URL configured, the instance is the job's site
+	site, _ := url.Parse(server.URL + "/acct/proj")
+
+	job := CrawlJob{
+		Port: types.PortInfo{
+			DistVersion: "1.9.0",
+			Forge: &types.ForgeInfo{
+				Type:    types.ForgeGitea,
+				Site:    server.URL,
+				Account: "acct",
+				Project: "proj",
+				TagName: "v1.9.0",
+			},
+			Config: types.PortConfig{
+				SkipBeta: true,
+			},
+		},
+		Site: site,
+	}
+
+	var result CrawlResult
+
+	err = h.Crawl(context.Background(), job, &result)
+
+	if err != nil {
+		t.Fatal("Crawl failed:", err)
+	}
+
+	expected := map[string]string{
+		"1.9.0": "/acct/proj/archive/v1.9.0.tar.gz",
+		"1.9.1": "/acct/proj/archive/v1.9.1.tar.gz",
+	}
+
+	if len(result.Releases) != len(expected) {
+		t.Fatal("Incorrect release count:", result.Releases)
+	}
+
+	for _, release := range result.Releases {
+		if server.URL+expected[release.Version] != release.File.String() {
+			t.Fatal("Unexpected release:", release.Version, release.File)
+		}
+	}
+
+	job.Port.Forge.Project = "missing"
+
+	err = h.Crawl(context.Background(), job, &result)
+
+	var statusErr *StatusError
+
+	if !errors.As(err, &statusErr) || statusErr.Code != http.StatusNotFound {
+		t.Fatal("Expected not found error for unknown project; got:", err)
+	}
+
+	job.Port.Forge.Project = "empty"
+	result = CrawlResult{}
+
+	err = h.Crawl(context.Background(), job, &result)
+
+	if err != nil {
+		t.Fatal("Crawl failed:", err)
+	}
+
+	if len(result.Releases) != 0 {
+		t.Fatal("Expected no releases for project without releases or tags:", result.Releases)
+	}
+
+	job.Port.Forge.Project = "proj"
+
+	h.token = ""
+
+	err = h.Crawl(context.Background(), job, &result)
+
+	if err == nil || !strings.Contains(err.Error(), "Gitea releases query failed") {
+		t.Fatal("Expected error without token; got:", err)
+	}
+}
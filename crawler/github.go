@@ -0,0 +1,176 @@
+package crawler
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"unicode"
+
+	"github.com/samott/portscout2/types"
+)
+
+const gitHubMaxPages = 10
+
+const gitHubDefaultApiUrl = "https://api.github.com"
+
+type GitHubHandler struct {
+	crawler *Crawler
+	apiUrl  *url.URL
+	token   string
+}
+
+type gitHubRelease struct {
+	TagName    string `json:"tag_name"`
+	Draft      bool   `json:"draft"`
+	Prerelease bool   `json:"prerelease"`
+}
+
+type gitHubTag struct {
+	Name string `json:"name"`
+}
+
+/**
+ * Creates a handler which lists the releases and tags of GitHub
+ * projects through the REST API at apiUrl (https://api.github.com
+ * if empty). The token is optional, but without one GitHub's rate
+ * limits are very low.
+ */
+func NewGitHubHandler(c *Crawler, apiUrl string, token string) (*GitHubHandler, error) {
+	u, err := parseApiUrl(apiUrl, gitHubDefaultApiUrl, "GitHub API")
+
+	if err != nil {
+		return nil, err
+	}
+
+	return &GitHubHandler{
+		crawler: c,
+		apiUrl:  u,
+		token:   token,
+	}, nil
+}
+
+/**
+ * Lists a GitHub project's releases and tags. Ports which download
+ * from github.com without USE_GITHUB (e.g. release assets in
+ * MASTER_SITES) have no GitHub information, so their sites are
+ * crawled as ordinary HTTP ones instead.
+ */
+func (h *GitHubHandler) Crawl(ctx context.Context, job CrawlJob, result *CrawlResult) error {
+	gh := job.Port.GitHub
+
+	if gh == nil {
+		return h.crawler.crawlHttp(ctx, job, result)
+	}
+
+	ghReleases, err := gitHubFetchAll[gitHubRelease](ctx, h, gh, "releases")
+
+	if err != nil {
+		return err
+	}
+
+	// Many projects tag releases without creating GitHub releases
+	ghTags, err := gitHubFetchAll[gitHubTag](ctx, h, gh, "tags")
+
+	if err != nil {
+		return err
+	}
+
+	releases := make([]forgeRelease, 0, len(ghReleases))
+
+	for _, release := range ghReleases {
+		releases = append(releases, forgeRelease{
+			Tag:        release.TagName,
+			Draft:      release.Draft,
+			Prerelease: release.Prerelease,
+		})
+	}
+
+	tags := make([]string, 0, len(ghTags))
+
+	for _, tag := range ghTags {
+		tags = append(tags, tag.Name)
+	}
+
+	result.Releases = tagReleases(job, gh.TagName, releases, tags, func(tag string) *url.URL {
+		return h.archiveUrl(job.Site, tag)
+	})
+
+	return nil
+}
+
+// Fetches every page of a repository collection ("releases" or "tags").
+func gitHubFetchAll[T any](ctx context.Context, h *GitHubHandler, gh *types.GitHubInfo, collection string) ([]T, error) {
+	u := h.apiUrl.JoinPath("repos", gh.Account, gh.Project, collection)
+	u.RawQuery = "per_page=100"
+
+	header := make(http.Header)
+	header.Set("Accept", "application/vnd.github+json")
+
+	if h.token != "" {
+		header.Set("Authorization", "Bearer "+h.token)
+	}
+
+	items, err := fetchAllPages[T](ctx, h.crawler, u, header, gitHubMaxPages)
+
+	if err != nil {
+		return nil, fmt.Errorf("GitHub %s query failed: %w", collection, err)
+	}
+
+	return items, nil
+}
+
+func (h *GitHubHandler) archiveUrl(site *url.URL, tag string) *url.URL {
+	return site.JoinPath("archive", "refs", "tags", tag+".tar.gz")
+}
+
+/**
+ * Maps a tag name back to a version, using the port's tag name
+ * (e.g. GH_TAGNAME) as a template: the current version is located
+ * within it and the surrounding text (e.g. "v" in "v1.2.3") is
+ * stripped from the tag.
+ *
+ * If the template doesn't contain the version (e.g. the port uses
+ * a commit hash) we fall back to stripping any non-numeric prefix
+ * which ends in "v" or a separator ("release-1.2"). Underscores
+ * are taken as dots ("1_2_3") if that's how the port's version
+ * is written.
+ */
+func versionFromTag(tagName string, current string, tag string) (string, bool) {
+	var ver string
+
+	if current != "" && strings.Contains(tagName, current) {
+		i := strings.Index(tagName, current)
+		prefix := tagName[:i]
+		suffix := tagName[i+len(current):]
+
+		if !strings.HasPrefix(tag, prefix) || !strings.HasSuffix(tag, suffix) {
+			return "", false
+		}
+
+		if len(tag) <= len(prefix)+len(suffix) {
+			return "", false
+		}
+
+		ver = tag[len(prefix) : len(tag)-len(suffix)]
+	} else {
+		i := strings.IndexFunc(tag, unicode.IsDigit)
+
+		if i < 0 || (i > 0 && !strings.ContainsRune("vV-_.", rune(tag[i-1]))) {
+			return "", false
+		}
+
+		ver = tag[i:]
+	}
+
+	if !unicode.IsDigit(rune(ver[0])) {
+		return "", false
+	}
+
+	if strings.Contains(current, ".") && !strings.Contains(ver, ".") {
+		ver = strings.ReplaceAll(ver, "_", ".")
+	}
+
+	return ver, true
+}
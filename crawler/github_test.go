@@ -0,0 +1,174 @@
+package crawler
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+
+	"github.com/samott/portscout2/types"
+)
+
+func TestGitHubHandler(t *testing.T) {
+	var server *httptest.Server
+
+	mux := http.NewServeMux()
+
+	mux.HandleFunc("GET /repos/acct/proj/releases", func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer secret" {
+			http.Error(w, "unauthorised", http.StatusUnauthorized)
+			return
+		}
+
+		if r.URL.Query().Get("page") == "" {
+			w.Header().Set("Link", fmt.Sprintf(`<%s/repos/acct/proj/releases?page=2>; rel="next"`, server.URL))
+			fmt.Fprint(w, `[
+				{"tag_name": "v1.3.0", "draft": false, "prerelease": false},
+				{"tag_name": "v1.4.0-rc1", "draft": false, "prerelease": true}
+			]`)
+			return
+		}
+
+		fmt.Fprint(w, `[
+			{"tag_name": "v1.5.0", "draft": true, "prerelease": false},
+			{"tag_name": "v1.2.0", "draft": false, "prerelease": false}
+		]`)
+	})
+
+	mux.HandleFunc("GET /repos/acct/proj/tags", func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, `[
+			{"name": "v1.4.0-rc1"},
+			{"name": "v1.3.1"},
+			{"name": "v1.3.0"},
+			{"name": "nightly"}
+		]`)
+	})
+
+	server = httptest.NewServer(mux)
+	defer server.Close()
+
+	c := NewCrawler(1)
+
+	h, err := NewGitHubHandler(c, server.URL, "secret")
+
+	if err != nil {
+		t.Fatal("NewGitHubHandler failed:", err)
+	}
+
+	site, _ := url.Parse("https://github.com/acct/proj")
+
+	job := CrawlJob{
+		Port: types.PortInfo{
+			DistVersion: "1.2.0",
+			GitHub: &types.GitHubInfo{
+				Account: "acct",
+				Project: "proj",
+				TagName: "v1.2.0",
+			},
+			Config: types.PortConfig{
+				SkipBeta: true,
+			},
+		},
+		Site: site,
+	}
+
+	var result CrawlResult
+
+	err = h.Crawl(context.Background(), job, &result)
+
+	if err != nil {
+		t.Fatal("Crawl failed:", err)
+	}
+
+	expected := map[string]string{
+		"1.3.0": "https://github.com/acct/proj/archive/refs/tags/v1.3.0.tar.gz",
+		"1.2.0": "https://github.com/acct/proj/archive/refs/tags/v1.2.0.tar.gz",
+		"1.3.1": "https://github.com/acct/proj/archive/refs/tags/v1.3.1.tar.gz",
+	}
+
+	if len(result.Releases) != len(expected) {
+		t.Fatal("Incorrect release count:", result.Releases)
+	}
+
+	for _, release := range result.Releases {
+		if expected[release.Version] != release.File.String() {
+			t.Fatal("Unexpected release:", release.Version, release.File)
+		}
+	}
+
+	h.token = ""
+
+	if h.Crawl(context.Background(), job, &result) == nil {
+		t.Fatal("Expected error without token")
+	}
+}
+
+func TestVersionFromTag(t *testing.T) {
+	cases := []struct {
+		tagName  string
+		current  string
+		tag      string
+		expected string
+		ok       bool
+	}{
+		{"v1.2.0", "1.2.0", "v1.3.0", "1.3.0", true},
+		{"v1.2.0", "1.2.0", "1.3.0", "", false},
+		{"proj-1.2.0-src", "1.2.0", "proj-1.3.0-src", "1.3.0", true},
+		{"proj-1.2.0-src", "1.2.0", "proj-1.3.0", "", false},
+		{"1.2.0", "1.2.0", "latest", "", false},
+		{"abcdef0", "1.2.0", "v1.3.0", "1.3.0", true},
+		{"abcdef0", "1.2.0", "release-1.3.0", "1.3.0", true},
+		{"abcdef0", "1.2.0", "release_1_3_0", "1.3.0", true},
+		{"abcdef0", "1.2.0", "x264", "", false},
+		{"abcdef0", "1.2.0", "nightly", "", false},
+	}
+
+	for _, tc := range cases {
+		ver, ok := versionFromTag(tc.tagName, tc.current, tc.tag)
+
+		if ok != tc.ok || ver != tc.expected {
+			t.Errorf("versionFromTag(%q, %q, %q) = %q, %v; expected %q, %v",
+				tc.tagName, tc.current, tc.tag, ver, ok, tc.expected, tc.ok)
+		}
+	}
+}
+
+func TestGitHubHandlerNoInfo(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, `<a href="foo-1.3.0.tar.gz">foo-1.3.0.tar.gz</a>`)
+	}))
+	defer server.Close()
+
+	c := NewCrawler(1)
+
+	h, err := NewGitHubHandler(c, server.URL, "")
+
+	if err != nil {
+		t.Fatal("NewGitHubHandler failed:", err)
+	}
+
+	// As for release assets listed in MASTER_SITES without USE_GITHUB
+	site, _ := url.Parse(server.URL + "/acct/proj/releases/download/v1.2.0/")
+
+	job := CrawlJob{
+		Port: types.PortInfo{
+			DistVersion: "1.2.0",
+		},
+		Site: site,
+		File: "foo-1.2.0.tar.gz",
+	}
+
+	var result CrawlResult
+
+	err = h.Crawl(context.Background(), job, &result)
+
+	if err != nil {
+		t.Fatal("Crawl failed:", err)
+	}
+
+	if len(result.Files) != 1 || result.Files[0].String() != server.URL+"/acct/proj/releases/download/v1.2.0/foo-1.3.0.tar.gz" {
+		t.Fatal("Site not crawled as HTTP:", result.Files)
+	}
+}
@@ -0,0 +1,129 @@
+package crawler
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+
+	"github.com/samott/portscout2/types"
+)
+
+const gitLabMaxPages = 10
+
+type GitLabHandler struct {
+	crawler *Crawler
+	apiUrl  *url.URL
+	token   string
+}
+
+type gitLabRelease struct {
+	TagName         string `json:"tag_name"`
+	UpcomingRelease bool   `json:"upcoming_release"`
+}
+
+type gitLabTag struct {
+	Name string `json:"name"`
+}
+
+/**
+ * Creates a handler which lists the releases and tags of GitLab
+ * projects through the REST API at apiUrl, or if that's empty at
+ * /api/v4 on the instance named by the port's GL_SITE, so that one
+ * handler can serve any number of self-hosted instances. The token
+ * is optional.
+ */
+func NewGitLabHandler(c *Crawler, apiUrl string, token string) (*GitLabHandler, error) {
+	h := &GitLabHandler{
+		crawler: c,
+		token:   token,
+	}
+
+	if apiUrl != "" {
+		u, err := url.Parse(apiUrl)
+
+		if err != nil {
+			return nil, fmt.Errorf("Invalid GitLab API URL: %w", err)
+		}
+
+		h.apiUrl = u
+	}
+
+	return h, nil
+}
+
+func (h *GitLabHandler) Crawl(ctx context.Context, job CrawlJob, result *CrawlResult) error {
+	forge := job.Port.Forge
+
+	if forge == nil {
+		return h.crawler.crawlHttp(ctx, job, result)
+	}
+
+	if forge.Type != types.ForgeGitLab {
+		return errors.New("Port has no GitLab information")
+	}
+
+	glReleases, err := gitLabFetchAll[gitLabRelease](ctx, h, job, "releases")
+
+	if err != nil {
+		return err
+	}
+
+	glTags, err := gitLabFetchAll[gitLabTag](ctx, h, job, "repository/tags")
+
+	if err != nil {
+		return err
+	}
+
+	releases := make([]forgeRelease, 0, len(glReleases))
+
+	for _, release := range glReleases {
+		releases = append(releases, forgeRelease{
+			Tag: release.TagName,
+			// Not yet released
+			Draft: release.UpcomingRelease,
+		})
+	}
+
+	tags := make([]string, 0, len(glTags))
+
+	for _, tag := range glTags {
+		tags = append(tags, tag.Name)
+	}
+
+	result.Releases = tagReleases(job, forge.TagName, releases, tags, func(tag string) *url.URL {
+		return job.Site.JoinPath("-", "archive", tag, forge.Project+"-"+tag+".tar.gz")
+	})
+
+	return nil
+}
+
+/**
+ * Fetches every page of a project collection. Projects are named
+ * by their URL-encoded path ("acct%2Fproj"), which can't be built
+ * with JoinPath alone.
+ */
+func gitLabFetchAll[T any](ctx context.Context, h *GitLabHandler, job CrawlJob, collection string) ([]T, error) {
+	forge := job.Port.Forge
+	project := forge.Account + "/" + forge.Project
+
+	base := forgeApiUrl(h.apiUrl, job.Site, "/api/v4")
+	u := base.JoinPath("projects", project, collection)
+	u.RawPath = base.JoinPath("projects").EscapedPath() + "/" + url.PathEscape(project) + "/" + collection
+	u.RawQuery = "per_page=100"
+
+	header := make(http.Header)
+
+	if h.token != "" {
+		header.Set("PRIVATE-TOKEN", h.token)
+	}
+
+	items, err := fetchAllPages[T](ctx, h.crawler, u, header, gitLabMaxPages)
+
+	if err != nil {
+		return nil, fmt.Errorf("GitLab %s query failed: %w", collection, err)
+	}
+
+	return items, nil
+}
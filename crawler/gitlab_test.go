@@ -0,0 +1,141 @@
+package crawler
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+
+	"github.com/samott/portscout2/types"
+)
+
+func TestGitLabHandler(t *testing.T) {
+	var server *httptest.Server
+
+	mux := http.NewServeMux()
+
+	mux.HandleFunc("GET /api/v4/projects/{project}/releases", func(w http.ResponseWriter, r *http.Request) {
+		if r.PathValue("project") == "acct/empty" {
+			fmt.Fprint(w, `[]`)
+			return
+		}
+
+		if r.PathValue("project") != "acct/proj" || r.URL.EscapedPath() != "/api/v4/projects/acct%2Fproj/releases" {
+			http.NotFound(w, r)
+			return
+		}
+
+		if r.Header.Get("PRIVATE-TOKEN") != "secret" {
+			http.Error(w, "unauthorised", http.StatusUnauthorized)
+			return
+		}
+
+		if r.URL.Query().Get("page") == "" {
+			w.Header().Set("Link", fmt.Sprintf(`<%s/api/v4/projects/acct%%2Fproj/releases?page=2&per_page=100>; rel="next"`, server.URL))
+			fmt.Fprint(w, `[
+				{"tag_name": "v1.3.0", "upcoming_release": false},
+				{"tag_name": "v1.5.0", "upcoming_release": true}
+			]`)
+			return
+		}
+
+		fmt.Fprint(w, `[{"tag_name": "v1.2.0", "upcoming_release": false}]`)
+	})
+
+	mux.HandleFunc("GET /api/v4/projects/{project}/repository/tags", func(w http.ResponseWriter, r *http.Request) {
+		if r.PathValue("project") == "acct/empty" {
+			fmt.Fprint(w, `[]`)
+			return
+		}
+
+		fmt.Fprint(w, `[
+			{"name": "v1.5.0"},
+			{"name": "v1.3.1"},
+			{"name": "nightly"}
+		]`)
+	})
+
+	server = httptest.NewServer(mux)
+	defer server.Close()
+
+	c := NewCrawler(1)
+
+	h, err := NewGitLabHandler(c, server.URL+"/api/v4", "secret")
+
+	if err != nil {
+		t.Fatal("NewGitLabHandler failed:", err)
+	}
+
+	site, _ := url.Parse("https://gitlab.example.org/acct/proj")
+
+	job := CrawlJob{
+		Port: types.PortInfo{
+			DistVersion: "1.2.0",
+			Forge: &types.ForgeInfo{
+				Type:    types.ForgeGitLab,
+				Site:    "https://gitlab.example.org",
+				Account: "acct",
+				Project: "proj",
+				TagName: "v1.2.0",
+			},
+		},
+		Site: site,
+	}
+
+	var result CrawlResult
+
+	err = h.Crawl(context.Background(), job, &result)
+
+	if err != nil {
+		t.Fatal("Crawl failed:", err)
+	}
+
+	expected := map[string]string{
+		"1.3.0": "https://gitlab.example.org/acct/proj/-/archive/v1.3.0/proj-v1.3.0.tar.gz",
+		"1.2.0": "https://gitlab.example.org/acct/proj/-/archive/v1.2.0/proj-v1.2.0.tar.gz",
+		"1.3.1": "https://gitlab.example.org/acct/proj/-/archive/v1.3.1/proj-v1.3.1.tar.gz",
+	}
+
+	if len(result.Releases) != len(expected) {
+		t.Fatal("Incorrect release count:", result.Releases)
+	}
+
+	for _, release := range result.Releases {
+		if expected[release.Version] != release.File.String() {
+			t.Fatal("Unexpected release:", release.Version, release.File)
+		}
+	}
+
+	job.Port.Forge.Project = "missing"
+
+	err = h.Crawl(context.Background(), job, &result)
+
+	var statusErr *StatusError
+
+	if !errors.As(err, &statusErr) || statusErr.Code != http.StatusNotFound {
+		t.Fatal("Expected not found error for unknown project; got:", err)
+	}
+
+	job.Port.Forge.Project = "empty"
+	result = CrawlResult{}
+
+	err = h.Crawl(context.Background(), job, &result)
+
+	if err != nil {
+		t.Fatal("Crawl failed:", err)
+	}
+
+	if len(result.Releases) != 0 {
+		t.Fatal("Expected no releases for project without releases or tags:", result.Releases)
+	}
+
+	job.Port.Forge.Project = "proj"
+	job.Port.Forge.Type = types.ForgeGitea
+
+	if h.Crawl(context.Background(), job, &result) == nil {
+		t.Fatal("Expected error for non-GitLab port")
+	}
+}
@@ -0,0 +1,133 @@
+package crawler
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"net/url"
+	"regexp"
+	"strings"
+	"unicode"
+)
+
+const goProxyDefaultUrl = "https://proxy.golang.org"
+
+// Pseudo-versions name untagged commits ("v0.0.0-20240101120000-abcdef012345").
+var goPseudoVersionRegex = regexp.MustCompile(`[-.][0-9]{14}-[0-9a-f]{12}$`)
+
+/**
+ * Crawls Go modules, planned as https://pkg.go.dev/<module> for
+ * the sake of a readable site, although pkg.go.dev has no API;
+ * versions come from a module proxy instead, which names modules
+ * in a case-escaped form (see escapeGoModule).
+ */
+type GoProxyHandler struct {
+	crawler  *Crawler
+	proxyUrl *url.URL
+}
+
+type goModuleInfo struct {
+	Version string `json:"Version"`
+}
+
+/**
+ * Creates a handler for the module proxy at proxyUrl, by default
+ * https://proxy.golang.org. Any proxy serving the GOPROXY protocol
+ * will do, including a private one for modules it doesn't mirror.
+ */
+func NewGoProxyHandler(c *Crawler, proxyUrl string) (*GoProxyHandler, error) {
+	u, err := parseApiUrl(proxyUrl, goProxyDefaultUrl, "Go proxy")
+
+	if err != nil {
+		return nil, err
+	}
+
+	return &GoProxyHandler{
+		crawler:  c,
+		proxyUrl: u,
+	}, nil
+}
+
+/**
+ * Reports the module's tagged versions, from /@v/list, or failing
+ * any its latest version from /@latest, provided that isn't a
+ * pseudo-version. Pre-releases are skipped if the port skips betas.
+ */
+func (h *GoProxyHandler) Crawl(ctx context.Context, job CrawlJob, result *CrawlResult) error {
+	module := strings.Trim(job.Site.Path, "/")
+
+	if module == "" {
+		return fmt.Errorf("Not a Go module URL: %s", job.Site)
+	}
+
+	base := h.proxyUrl.JoinPath(escapeGoModule(module), "@v")
+	versions := make([]string, 0)
+
+	_, err := h.crawler.fetchApi(ctx, base.JoinPath("list"), nil, func(r io.Reader) error {
+		scanner := bufio.NewScanner(r)
+
+		for scanner.Scan() {
+			if ver := strings.TrimSpace(scanner.Text()); ver != "" {
+				versions = append(versions, ver)
+			}
+		}
+
+		return scanner.Err()
+	})
+
+	if err != nil {
+		return fmt.Errorf("Go proxy list query failed: %w", err)
+	}
+
+	if len(versions) == 0 {
+		var latest goModuleInfo
+
+		_, err = h.crawler.fetchJson(ctx, h.proxyUrl.JoinPath(escapeGoModule(module), "@latest"), nil, &latest)
+
+		if err != nil {
+			return fmt.Errorf("Go proxy latest query failed: %w", err)
+		}
+
+		versions = append(versions, latest.Version)
+	}
+
+	for _, ver := range versions {
+		if goPseudoVersionRegex.MatchString(ver) {
+			continue
+		}
+
+		// Build metadata ("+incompatible") isn't a pre-release
+		if pre, _, _ := strings.Cut(ver, "+"); job.Port.Config.SkipBeta && strings.Contains(pre, "-") {
+			continue
+		}
+
+		result.Releases = append(result.Releases, Release{
+			Version: semverVersion(ver, job.Port.DistVersion),
+			File:    base.JoinPath(ver + ".zip"),
+		})
+	}
+
+	return nil
+}
+
+/**
+ * Escapes a module path for the proxy protocol, which has to work
+ * on case-insensitive file systems: upper case letters are replaced
+ * with "!" followed by the lower case letter.
+ */
+func escapeGoModule(module string) string {
+	var b strings.Builder
+
+	for _, r := range module {
+		if unicode.IsUpper(r) {
+			b.WriteByte('!')
+			b.WriteRune(unicode.ToLower(r))
+			continue
+		}
+
+		b.WriteRune(r)
+	}
+
+	return b.String()
+}
@@ -0,0 +1,121 @@
+package crawler
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/samott/portscout2/types"
+)
+
+func TestGoProxyHandler(t *testing.T) {
+	mux := http.NewServeMux()
+
+	mux.HandleFunc("GET /github.com/!acct/proj/@v/list", func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, "v1.2.0\nv1.3.0\nv1.4.0-rc.1\nv2.0.0+incompatible\n")
+	})
+
+	mux.HandleFunc("GET /example.org/untagged/@v/list", func(w http.ResponseWriter, r *http.Request) {
+	})
+
+	mux.HandleFunc("GET /example.org/untagged/@latest", func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, `{"Version": "v0.0.0-20240101120000-abcdef012345"}`)
+	})
+
+	mux.HandleFunc("GET /example.org/gone/@v/list", func(w http.ResponseWriter, r *http.Request) {
+	})
+
+	server := httptest.NewServer(mux)
+	defer server.Close()
+
+	c := NewCrawler(1)
+
+	h, err := NewGoProxyHandler(c, server.URL)
+
+	if err != nil {
+		t.Fatal("NewGoProxyHandler failed:", err)
+	}
+
+	site, _ := url.Parse("https://pkg.go.dev/github.com/Acct/proj")
+
+	job := CrawlJob{
+		Port: types.PortInfo{
+			DistVersion: "1.2.0",
+			Config: types.PortConfig{
+				SkipBeta: true,
+			},
+		},
+		Site: site,
+	}
+
+	var result CrawlResult
+
+	err = h.Crawl(context.Background(), job, &result)
+
+	if err != nil {
+		t.Fatal("Crawl failed:", err)
+	}
+
+	expected := map[string]string{
+		"1.2.0": "/github.com/!acct/proj/@v/v1.2.0.zip",
+		"1.3.0": "/github.com/!acct/proj/@v/v1.3.0.zip",
+		"2.0.0": "/github.com/!acct/proj/@v/v2.0.0+incompatible.zip",
+	}
+
+	if len(result.Releases) != len(expected) {
+		t.Fatal("Incorrect release count:", result.Releases)
+	}
+
+	for _, release := range result.Releases {
+		if server.URL+expected[release.Version] != release.File.String() {
+			t.Fatal("Unexpected release:", release.Version, release.File)
+		}
+	}
+
+	job.Site, _ = url.Parse("https://pkg.go.dev/example.org/untagged")
+	result = CrawlResult{}
+
+	err = h.Crawl(context.Background(), job, &result)
+
+	if err != nil {
+		t.Fatal("Crawl failed:", err)
+	}
+
+	if len(result.Releases) != 0 {
+		t.Fatal("Expected pseudo-version to be ignored:", result.Releases)
+	}
+
+	job.Site, _ = url.Parse("https://pkg.go.dev/")
+	result = CrawlResult{}
+
+	err = h.Crawl(context.Background(), job, &result)
+
+	if err == nil || !strings.Contains(err.Error(), "Not a Go module URL") {
+		t.Fatal("Expected error for no module path; got:", err)
+	}
+
+	job.Site, _ = url.Parse("https://pkg.go.dev/example.org/missing")
+	result = CrawlResult{}
+
+	err = h.Crawl(context.Background(), job, &result)
+
+	var statusErr *StatusError
+
+	if !errors.As(err, &statusErr) || statusErr.Code != http.StatusNotFound {
+		t.Fatal("Expected not found error for unknown module; got:", err)
+	}
+
+	job.Site, _ = url.Parse("https://pkg.go.dev/example.org/gone")
+	result = CrawlResult{}
+
+	err = h.Crawl(context.Background(), job, &result)
+
+	if !errors.As(err, &statusErr) || statusErr.Code != http.StatusNotFound {
+		t.Fatal("Expected not found error for no latest version; got:", err)
+	}
+}
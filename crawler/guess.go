@@ -0,0 +1,206 @@
+package crawler
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/samott/portscout2/version"
+)
+
+// Upper bound on the number of probes made for a single job.
+const maxGuessProbes = 20
+
+// A prober checks whether a file exists on a site without
+// downloading it.
+type prober func(ctx context.Context, file *url.URL) (bool, error)
+
+/**
+ * Falls back to guessing when a listing failed, or didn't contain
+ * anything recognisable as one of the port's distfiles, and guess
+ * mode is enabled. Hits are added to the result as releases.
+ *
+ * The listing error is returned unless guessing was able to reach
+ * the site, since a definite "not found" for each guess is as good
+ * an answer as a listing.
+ */
+func (c *Crawler) guessIfNeeded(ctx context.Context, job CrawlJob, result *CrawlResult, listErr error, probe prober) error {
+	if !c.guess {
+		return listErr
+	}
+
+	if listErr == nil && len(version.NewMatcher(job.Port).Candidates(result.Files)) > 0 {
+		return nil
+	}
+
+	releases, err := c.guessVersions(ctx, job, probe)
+
+	if err != nil {
+		if listErr != nil {
+			return listErr
+		}
+
+		return err
+	}
+
+	result.Releases = append(result.Releases, releases...)
+
+	return nil
+}
+
+/**
+ * Probes the site for distfiles named after likely next versions,
+ * as produced by version.GenerateGuesses. Each hit is itself used
+ * to generate further guesses, so that several releases can be
+ * skipped over (1.2.3 -> 1.2.4 -> 1.2.5).
+ */
+func (c *Crawler) guessVersions(ctx context.Context, job CrawlJob, probe prober) ([]Release, error) {
+	releases := make([]Release, 0)
+
+	current := job.Port.DistVersion
+
+	if current == "" || !strings.Contains(job.File, current) {
+		return releases, nil
+	}
+
+	evenOdd := -1
+
+	if job.Port.Config.LimitWhich >= 0 {
+		evenOdd = job.Port.Config.LimitWhich
+	}
+
+	queue := version.GenerateGuesses(current, evenOdd)
+	tried := make(map[string]bool)
+	probes := 0
+
+	for len(queue) > 0 && probes < maxGuessProbes {
+		guess := queue[0]
+		queue = queue[1:]
+
+		if tried[guess] {
+			continue
+		}
+
+		tried[guess] = true
+
+		if version.Check(job.Port.Config, current, guess) != nil {
+			continue
+		}
+
+		file := guessUrl(job, guess)
+
+		probes++
+
+		found, err := probe(ctx, file)
+
+		if err != nil {
+			return nil, fmt.Errorf("Guess probe failed: %w", err)
+		}
+
+		if !found {
+			continue
+		}
+
+		releases = append(releases, Release{
+			Version: guess,
+			File:    file,
+		})
+
+		queue = append(queue, version.GenerateGuesses(guess, evenOdd)...)
+	}
+
+	return releases, nil
+}
+
+// Builds the URL of the job's distfile with the version replaced.
+func guessUrl(job CrawlJob, guess string) *url.URL {
+	name := strings.ReplaceAll(job.File, job.Port.DistVersion, guess)
+
+	return job.Site.JoinPath(name)
+}
+
+/**
+ * Checks for a file with a HEAD request, falling back to a ranged
+ * GET for servers which don't implement HEAD.
+ */
+func (c *Crawler) probeHttp(ctx context.Context, file *url.URL) (bool, error) {
+	if err := c.checkRobots(ctx, file); err != nil {
+		return false, err
+	}
+
+	resp, err := c.probeHttpMethod(ctx, file, "HEAD")
+
+	if err != nil {
+		return false, err
+	}
+
+	if resp.StatusCode == http.StatusMethodNotAllowed || resp.StatusCode == http.StatusNotImplemented {
+		resp, err = c.probeHttpMethod(ctx, file, "GET")
+
+		if err != nil {
+			return false, err
+		}
+	}
+
+	switch status := resp.StatusCode; {
+	case status >= 200 && status <= 299:
+		return true, nil
+	case status == http.StatusNotFound || status == http.StatusGone || status == http.StatusForbidden:
+		return false, nil
+	default:
+		return false, fmt.Errorf("Unexpected status probing %s: %w", file, newStatusError(resp))
+	}
+}
+
+// Returns the response, with its body already closed.
+func (c *Crawler) probeHttpMethod(ctx context.Context, file *url.URL, method string) (*http.Response, error) {
+	req, err := http.NewRequestWithContext(ctx, method, file.String(), nil)
+
+	if err != nil {
+		return nil, fmt.Errorf("Error creating request: %w", err)
+	}
+
+	req.Header.Set("User-Agent", "portscout/2")
+
+	if method == "GET" {
+		req.Header.Set("Range", "bytes=0-0")
+	}
+
+	resp, err := c.httpClient().Do(req)
+
+	if err != nil {
+		return nil, fmt.Errorf("Error making request: %w", err)
+	}
+
+	resp.Body.Close()
+
+	return resp, nil
+}
+
+// Checks for a file using the FTP SIZE command.
+func (c *Crawler) probeFtp(ctx context.Context, file *url.URL) (bool, error) {
+	client, err := c.dialFtp(ctx, file)
+
+	if err != nil {
+		return false, err
+	}
+
+	defer client.Close()
+
+	_, err = client.FileSize(file.Path)
+
+	if err != nil {
+		client.check(err)
+
+		// Any 5xx reply (typically 550) means no such file
+		if isFtpPermanentError(err) {
+			return false, nil
+		}
+
+		return false, fmt.Errorf("FTP size failed: %w", err)
+	}
+
+	return true, nil
+}
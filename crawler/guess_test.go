@@ -0,0 +1,89 @@
+package crawler
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+
+	"github.com/samott/portscout2/types"
+)
+
+func TestCrawlHttpGuess(t *testing.T) {
+	existing := map[string]bool{
+		"/dl/foo-1.2.3.tar.gz": true,
+		"/dl/foo-1.2.4.tar.gz": true,
+		"/dl/foo-1.2.5.tar.gz": true,
+		"/dl/foo-1.3.0.tar.gz": true,
+	}
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/dl/" {
+			http.Error(w, "forbidden", http.StatusForbidden)
+			return
+		}
+
+		// Some servers refuse HEAD requests
+		if r.Method == "HEAD" && r.URL.Path == "/dl/foo-1.3.0.tar.gz" {
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+
+		if !existing[r.URL.Path] {
+			http.NotFound(w, r)
+			return
+		}
+
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer server.Close()
+
+	site, _ := url.Parse(server.URL + "/dl/")
+
+	job := CrawlJob{
+		Port: types.PortInfo{
+			DistName:    "foo-1.2.3",
+			DistVersion: "1.2.3",
+			Config: types.PortConfig{
+				LimitWhich: -1,
+			},
+		},
+		Site: site,
+		File: "foo-1.2.3.tar.gz",
+	}
+
+	c := NewCrawler(1)
+
+	var result CrawlResult
+
+	if c.crawlHttp(context.Background(), job, &result) == nil {
+		t.Fatal("Expected error with guessing disabled")
+	}
+
+	c.SetGuess(true)
+
+	result = CrawlResult{}
+
+	err := c.crawlHttp(context.Background(), job, &result)
+
+	if err != nil {
+		t.Fatal("crawlHttp failed:", err)
+	}
+
+	found := make(map[string]bool)
+
+	for _, release := range result.Releases {
+		found[release.Version] = true
+	}
+
+	for _, ver := range []string{"1.2.4", "1.2.5", "1.3.0"} {
+		if !found[ver] {
+			t.Fatal("Version not guessed:", ver, result.Releases)
+		}
+	}
+
+	if len(found) != 3 {
+		t.Fatal("Incorrect guesses:", result.Releases)
+	}
+}
@@ -0,0 +1,76 @@
+package crawler
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"strings"
+)
+
+const hackageDefaultUrl = "https://hackage.haskell.org"
+
+/**
+ * Crawls Haskell packages, planned as
+ * https://hackage.haskell.org/package/<name>. Hackage's "preferred"
+ * document separates the normal versions from those deprecated by
+ * the package's maintainers, and the source tarball of each version
+ * lives at a predictable location, so one request suffices.
+ */
+type HackageHandler struct {
+	crawler *Crawler
+	baseUrl *url.URL
+}
+
+type hackagePreferred struct {
+	NormalVersion []string `json:"normal-version"`
+}
+
+/**
+ * Creates a handler for the Hackage server at baseUrl, the central
+ * one at https://hackage.haskell.org if empty. Mirrors of it serve
+ * the same package pages and tarballs.
+ */
+func NewHackageHandler(c *Crawler, baseUrl string) (*HackageHandler, error) {
+	u, err := parseApiUrl(baseUrl, hackageDefaultUrl, "Hackage")
+
+	if err != nil {
+		return nil, err
+	}
+
+	return &HackageHandler{
+		crawler: c,
+		baseUrl: u,
+	}, nil
+}
+
+/**
+ * Reports the package's normal versions with their source
+ * tarballs; deprecated versions, which the maintainers have asked
+ * users to avoid, are left out. Hackage has no pre-releases.
+ */
+func (h *HackageHandler) Crawl(ctx context.Context, job CrawlJob, result *CrawlResult) error {
+	name, ok := strings.CutPrefix(strings.TrimSuffix(job.Site.Path, "/"), "/package/")
+
+	if !ok || name == "" || strings.Contains(name, "/") {
+		return fmt.Errorf("Not a Hackage package URL: %s", job.Site)
+	}
+
+	var preferred hackagePreferred
+
+	_, err := h.crawler.fetchJson(ctx, h.baseUrl.JoinPath("package", name, "preferred"), nil, &preferred)
+
+	if err != nil {
+		return fmt.Errorf("Hackage query failed: %w", err)
+	}
+
+	for _, ver := range preferred.NormalVersion {
+		release := name + "-" + ver
+
+		result.Releases = append(result.Releases, Release{
+			Version: ver,
+			File:    h.baseUrl.JoinPath("package", release, release+".tar.gz"),
+		})
+	}
+
+	return nil
+}
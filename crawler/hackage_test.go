@@ -0,0 +1,109 @@
+package crawler
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/samott/portscout2/types"
+)
+
+func TestHackageHandler(t *testing.T) {
+	mux := http.NewServeMux()
+
+	mux.HandleFunc("GET /package/pandoc/preferred", func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Accept") != "application/json" {
+			http.Error(w, "not acceptable", http.StatusNotAcceptable)
+			return
+		}
+
+		fmt.Fprint(w, `{"normal-version": ["3.1.2", "3.1.1"], "deprecated-version": ["3.1.1.1"]}`)
+	})
+
+	mux.HandleFunc("GET /package/empty/preferred", func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, `{"normal-version": [], "deprecated-version": ["0.1"]}`)
+	})
+
+	server := httptest.NewServer(mux)
+	defer server.Close()
+
+	c := NewCrawler(1)
+
+	h, err := NewHackageHandler(c, server.URL)
+
+	if err != nil {
+		t.Fatal("NewHackageHandler failed:", err)
+	}
+
+	site, _ := url.Parse("https://hackage.haskell.org/package/pandoc")
+
+	job := CrawlJob{
+		Port: types.PortInfo{
+			DistVersion: "3.1.1",
+		},
+		Site: site,
+	}
+
+	var result CrawlResult
+
+	err = h.Crawl(context.Background(), job, &result)
+
+	if err != nil {
+		t.Fatal("Crawl failed:", err)
+	}
+
+	if len(result.Releases) != 2 {
+		t.Fatal("Incorrect release count:", result.Releases)
+	}
+
+	if release := result.Releases[0]; release.Version != "3.1.2" || release.File.String() != server.URL+"/package/pandoc-3.1.2/pandoc-3.1.2.tar.gz" {
+		t.Fatal("Unexpected release:", release.Version, release.File)
+	}
+
+	job.Site, _ = url.Parse("https://hackage.haskell.org/package/")
+	result = CrawlResult{}
+
+	err = h.Crawl(context.Background(), job, &result)
+
+	if err == nil || !strings.Contains(err.Error(), "Not a Hackage package URL") {
+		t.Fatal("Expected error for no package name; got:", err)
+	}
+
+	job.Site, _ = url.Parse("https://hackage.haskell.org/package/pandoc/docs")
+	result = CrawlResult{}
+
+	err = h.Crawl(context.Background(), job, &result)
+
+	if err == nil || !strings.Contains(err.Error(), "Not a Hackage package URL") {
+		t.Fatal("Expected error for package version page; got:", err)
+	}
+
+	job.Site, _ = url.Parse("https://hackage.haskell.org/package/missing")
+	result = CrawlResult{}
+
+	err = h.Crawl(context.Background(), job, &result)
+
+	var statusErr *StatusError
+
+	if !errors.As(err, &statusErr) || statusErr.Code != http.StatusNotFound {
+		t.Fatal("Expected not found error for unknown package; got:", err)
+	}
+
+	job.Site, _ = url.Parse("https://hackage.haskell.org/package/empty")
+	result = CrawlResult{}
+
+	err = h.Crawl(context.Background(), job, &result)
+
+	if err != nil {
+		t.Fatal("Crawl failed:", err)
+	}
+
+	if len(result.Releases) != 0 {
+		t.Fatal("Expected deprecated versions to be ignored:", result.Releases)
+	}
+}
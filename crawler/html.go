@@ -0,0 +1,253 @@
+package crawler
+
+import (
+	"io"
+	"net/url"
+	"path"
+	"strings"
+
+	"golang.org/x/net/html"
+
+	"github.com/samott/portscout2/types"
+)
+
+/**
+ * Extracts candidate file links from an HTML document (typically
+ * a server-generated directory listing) and resolves them against
+ * the page URL, or the document's <base> URL if it declares one.
+ *
+ * Links to subdirectories of the page are returned separately.
+ * Links pointing back at the listing itself (such as Apache's
+ * column sort links, "?C=N;O=D") or at parent directories are
+ * discarded, as are non-HTTP/FTP schemes.
+ *
+ * The text following each file link, up to the next link or the
+ * end of its table row or line, is kept; in a directory listing
+ * it holds the file's modification time and size, which are read
+ * according to the listing's flavour.
+ */
+func parseHtmlLinks(page *url.URL, r io.Reader) (listing, listingFlavour, error) {
+	dir := listing{
+		files: make([]*url.URL, 0),
+		dirs:  make([]*url.URL, 0),
+	}
+
+	seen := make(map[string]bool)
+	trailing := make(map[string]string)
+	hints := flavourHints{}
+
+	base := page
+	tokenizer := html.NewTokenizer(r)
+
+	// The file link whose trailing text we're collecting, if any
+	current := ""
+	var text strings.Builder
+
+	finish := func() {
+		if current != "" {
+			trailing[current] = text.String()
+		}
+
+		current = ""
+		text.Reset()
+	}
+
+	inPre := false
+	inAnchor := false
+	pending := ""
+
+	for {
+		tt := tokenizer.Next()
+
+		if tt == html.ErrorToken {
+			if tokenizer.Err() == io.EOF {
+				break
+			}
+
+			return listing{}, flavourGeneric, tokenizer.Err()
+		}
+
+		if tt == html.TextToken {
+			chunk := string(tokenizer.Text())
+
+			hints.text(chunk)
+
+			if current == "" || inAnchor {
+				continue
+			}
+
+			if inPre {
+				if before, _, found := strings.Cut(chunk, "\n"); found {
+					text.WriteString(before)
+					finish()
+					continue
+				}
+			}
+
+			text.WriteString(chunk)
+			continue
+		}
+
+		name, hasAttr := tokenizer.TagName()
+		tag := string(name)
+
+		// Keep table cells apart
+		if current != "" {
+			text.WriteByte(' ')
+		}
+
+		if tt == html.EndTagToken {
+			hints.endTag(tag)
+
+			switch tag {
+			case "a":
+				inAnchor = false
+
+				if pending != "" {
+					finish()
+					current = pending
+					pending = ""
+				}
+			case "tr":
+				finish()
+			case "pre":
+				finish()
+				inPre = false
+			}
+
+			continue
+		}
+
+		if tt != html.StartTagToken && tt != html.SelfClosingTagToken {
+			continue
+		}
+
+		attrs := make(map[string]string)
+
+		for hasAttr {
+			var key, val []byte
+
+			key, val, hasAttr = tokenizer.TagAttr()
+			attrs[string(key)] = strings.TrimSpace(string(val))
+		}
+
+		hints.startTag(tag, attrs)
+
+		switch tag {
+		case "pre":
+			inPre = true
+			continue
+		case "tr":
+			finish()
+			continue
+		case "a", "base":
+		default:
+			continue
+		}
+
+		if tag == "a" {
+			finish()
+			inAnchor = tt == html.StartTagToken
+		}
+
+		href := attrs["href"]
+
+		if href == "" {
+			continue
+		}
+
+		if tag == "base" {
+			// Only the first <base> is honoured, as per the spec
+			if base == page {
+				if u, err := page.Parse(href); err == nil {
+					base = u
+				}
+			}
+			continue
+		}
+
+		link, err := base.Parse(href)
+
+		if err != nil {
+			continue
+		}
+
+		link.Fragment = ""
+		link.RawFragment = ""
+
+		if seen[link.String()] {
+			continue
+		}
+
+		seen[link.String()] = true
+
+		if isFileLink(page, link) {
+			dir.files = append(dir.files, link)
+
+			if inAnchor {
+				pending = link.String()
+			}
+		} else if isSubdirLink(page, link) {
+			dir.dirs = append(dir.dirs, link)
+		}
+	}
+
+	finish()
+
+	flavour := hints.flavour()
+
+	for _, file := range dir.files {
+		info, ok := parseFileInfo(trailing[file.String()], flavour.dateLayouts())
+
+		if !ok {
+			continue
+		}
+
+		if dir.info == nil {
+			dir.info = make(map[string]types.FileInfo)
+		}
+
+		dir.info[file.String()] = info
+	}
+
+	return dir, flavour, nil
+}
+
+func isFileLink(page *url.URL, link *url.URL) bool {
+	switch link.Scheme {
+	case "http", "https", "ftp":
+	default:
+		return false
+	}
+
+	if link.Path == "" || strings.HasSuffix(link.Path, "/") {
+		// Directory, or the listing itself with a query string
+		return false
+	}
+
+	if link.Host == page.Host && path.Clean(link.Path) == path.Clean(page.Path) {
+		return false
+	}
+
+	return true
+}
+
+func isSubdirLink(page *url.URL, link *url.URL) bool {
+	if link.Scheme != page.Scheme || link.Host != page.Host || link.RawQuery != "" {
+		return false
+	}
+
+	if !strings.HasSuffix(link.Path, "/") {
+		return false
+	}
+
+	dir := page.Path
+
+	if dir == "" {
+		dir = "/"
+	} else if !strings.HasSuffix(dir, "/") {
+		dir = path.Dir(dir) + "/"
+	}
+
+	return strings.HasPrefix(link.Path, dir) && len(link.Path) > len(dir)
+}
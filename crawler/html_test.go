@@ -0,0 +1,80 @@
+package crawler
+
+import (
+	"net/url"
+	"strings"
+	"testing"
+)
+
+func TestParseHtmlLinks(t *testing.T) {
+	page, _ := url.Parse("http://www.example.net/pub/foo/")
+
+	doc := `<html><body>
+		<a href="?C=N;O=D">Name</a>
+		<a href="../">Parent Directory</a>
+		<a href="subdir/">subdir/</a>
+		<a href="foo-1.0.tar.gz">foo-1.0.tar.gz</a>
+		<a href="foo-1.1.tar.gz#top">foo-1.1.tar.gz</a>
+		<a href="/pub/foo/foo-1.2.tar.gz?download=1">foo-1.2.tar.gz</a>
+		<a href="https://mirror.example.org/foo-1.3.tar.gz">foo-1.3.tar.gz</a>
+		<a href="foo-1.0.tar.gz">duplicate</a>
+		<a href="mailto:someone@example.net">mail</a>
+		<a name="anchor">no href</a>
+	</body></html>`
+
+	dir, _, err := parseHtmlLinks(page, strings.NewReader(doc))
+
+	if err != nil {
+		t.Fatal("parseHtmlLinks failed:", err)
+	}
+
+	files, dirs := dir.files, dir.dirs
+
+	if len(dirs) != 1 || dirs[0].String() != "http://www.example.net/pub/foo/subdir/" {
+		t.Fatal("Incorrect subdirectories:", dirs)
+	}
+
+	expected := []string{
+		"http://www.example.net/pub/foo/foo-1.0.tar.gz",
+		"http://www.example.net/pub/foo/foo-1.1.tar.gz",
+		"http://www.example.net/pub/foo/foo-1.2.tar.gz?download=1",
+		"https://mirror.example.org/foo-1.3.tar.gz",
+	}
+
+	if len(files) != len(expected) {
+		t.Fatal("Incorrect link count:", files)
+	}
+
+	for i, file := range files {
+		if file.String() != expected[i] {
+			t.Fatal("Incorrect link:", file.String())
+		}
+	}
+}
+
+func TestParseHtmlLinksBase(t *testing.T) {
+	page, _ := url.Parse("http://www.example.net/index.php")
+
+	doc := `<html><head>
+		<base href="http://dl.example.net/releases/">
+		<base href="http://ignored.example.net/">
+	</head><body>
+		<a href="foo-1.0.tar.gz">foo-1.0.tar.gz</a>
+	</body></html>`
+
+	dir, _, err := parseHtmlLinks(page, strings.NewReader(doc))
+
+	if err != nil {
+		t.Fatal("parseHtmlLinks failed:", err)
+	}
+
+	files := dir.files
+
+	if len(files) != 1 {
+		t.Fatal("Incorrect link count:", files)
+	}
+
+	if files[0].String() != "http://dl.example.net/releases/foo-1.0.tar.gz" {
+		t.Fatal("Incorrect link:", files[0].String())
+	}
+}
@@ -0,0 +1,93 @@
+package crawler
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/url"
+	"sync"
+	"time"
+)
+
+/**
+ * Applies the crawler's rate limiter to each HTTP request made,
+ * including each hop of a redirect. The limiter's slot is held
+ * until the response body is closed, while the response time it
+ * is told about is the time taken to receive the headers.
+ */
+type limitedTransport struct {
+	crawler *Crawler
+	base    http.RoundTripper
+}
+
+type limitedBody struct {
+	io.ReadCloser
+	release func()
+}
+
+func (t *limitedTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	release, err := t.crawler.acquire(req.Context(), req.URL)
+
+	if err != nil {
+		return nil, err
+	}
+
+	start := time.Now()
+
+	resp, err := t.base.RoundTrip(req)
+
+	if err != nil {
+		release(time.Since(start), false)()
+		return nil, err
+	}
+
+	throttled := resp.StatusCode == http.StatusTooManyRequests || resp.StatusCode == http.StatusServiceUnavailable
+
+	resp.Body = &limitedBody{
+		ReadCloser: resp.Body,
+		release:    release(time.Since(start), throttled),
+	}
+
+	return resp, nil
+}
+
+func (b *limitedBody) Close() error {
+	err := b.ReadCloser.Close()
+	b.release()
+
+	return err
+}
+
+/**
+ * Waits for the limiter, if there is one, to allow a request to
+ * the site. The returned function records the outcome, giving back
+ * a function which releases the limiter's slot; that one is safe
+ * to call more than once.
+ */
+func (c *Crawler) acquire(ctx context.Context, site *url.URL) (func(time.Duration, bool) func(), error) {
+	if c.limiter == nil {
+		return func(time.Duration, bool) func() {
+			return func() {}
+		}, ctx.Err()
+	}
+
+	if err := c.limiter.Wait(site, ctx); err != nil {
+		return nil, err
+	}
+
+	return func(latency time.Duration, throttled bool) func() {
+		return sync.OnceFunc(func() {
+			c.limiter.Done(site, latency, throttled)
+		})
+	}, nil
+}
+
+func (c *Crawler) httpClient() *http.Client {
+	return &http.Client{
+		Timeout: c.requestTimeout,
+		Transport: &limitedTransport{
+			crawler: c,
+			base:    http.DefaultTransport,
+		},
+	}
+}
@@ -0,0 +1,108 @@
+package crawler
+
+import (
+	"context"
+	"fmt"
+	"maps"
+	"net/url"
+	"slices"
+	"strings"
+
+	"github.com/samott/portscout2/version"
+)
+
+const npmDefaultRegistryUrl = "https://registry.npmjs.org"
+
+/**
+ * Crawls npm packages, planned as https://www.npmjs.com/package/<name>.
+ * The registry has a single document per package covering every
+ * version, fetched from /<name>; the slash of a scoped name
+ * ("@scope/name") has to be escaped there, unlike on the website.
+ */
+type NpmHandler struct {
+	crawler     *Crawler
+	registryUrl *url.URL
+}
+
+type npmPackage struct {
+	Versions map[string]npmVersion `json:"versions"`
+}
+
+type npmVersion struct {
+	Dist struct {
+		Tarball string `json:"tarball"`
+	} `json:"dist"`
+	Deprecated any `json:"deprecated"`
+}
+
+/**
+ * Creates a handler for the registry at registryUrl, by default
+ * the public one at https://registry.npmjs.org. Mirrors and private
+ * registries serve the same package documents, so work equally.
+ */
+func NewNpmHandler(c *Crawler, registryUrl string) (*NpmHandler, error) {
+	u, err := parseApiUrl(registryUrl, npmDefaultRegistryUrl, "npm registry")
+
+	if err != nil {
+		return nil, err
+	}
+
+	return &NpmHandler{
+		crawler:     c,
+		registryUrl: u,
+	}, nil
+}
+
+/**
+ * Reports every version with its tarball, except deprecated ones
+ * and, if the port skips betas, pre-releases ("2.0.0-beta.1").
+ */
+func (h *NpmHandler) Crawl(ctx context.Context, job CrawlJob, result *CrawlResult) error {
+	name, ok := strings.CutPrefix(job.Site.Path, "/package/")
+
+	if !ok || name == "" {
+		return fmt.Errorf("Not an npm package URL: %s", job.Site)
+	}
+
+	// Scoped packages ("@scope/name") are requested as "@scope%2Fname"
+	u := *h.registryUrl
+	u.Path = strings.TrimSuffix(h.registryUrl.Path, "/") + "/" + name
+	u.RawPath = strings.TrimSuffix(h.registryUrl.EscapedPath(), "/") + "/" + url.PathEscape(name)
+
+	var pkg npmPackage
+
+	_, err := h.crawler.fetchJson(ctx, &u, nil, &pkg)
+
+	if err != nil {
+		return fmt.Errorf("npm query failed: %w", err)
+	}
+
+	// Oldest first, rather than in random map order
+	vers := slices.Sorted(maps.Keys(pkg.Versions))
+	slices.SortStableFunc(vers, version.Compare)
+
+	for _, ver := range vers {
+		info := pkg.Versions[ver]
+
+		if deprecated, _ := info.Deprecated.(string); deprecated != "" {
+			continue
+		}
+
+		if job.Port.Config.SkipBeta && strings.Contains(ver, "-") {
+			continue
+		}
+
+		file, err := url.Parse(info.Dist.Tarball)
+
+		if err != nil || info.Dist.Tarball == "" {
+			continue
+		}
+
+		result.Releases = append(result.Releases, Release{
+			Version: semverVersion(ver, job.Port.DistVersion),
+			File:    file,
+		})
+	}
+
+	return nil
+}
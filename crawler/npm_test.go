@@ -0,0 +1,129 @@
+package crawler
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/samott/portscout2/types"
+)
+
+func TestNpmHandler(t *testing.T) {
+	mux := http.NewServeMux()
+
+	mux.HandleFunc("GET /{name}", func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.EscapedPath() == "/empty" {
+			fmt.Fprint(w, `{"versions": {}}`)
+			return
+		}
+
+		if r.URL.EscapedPath() != "/@scope%2Fpkg" {
+			http.NotFound(w, r)
+			return
+		}
+
+		fmt.Fprint(w, `{"versions": {
+			"1.0.0": {"dist": {"tarball": "https://registry.example.org/@scope/pkg/-/pkg-1.0.0.tgz"}},
+			"1.1.0": {"dist": {"tarball": "https://registry.example.org/@scope/pkg/-/pkg-1.1.0.tgz"}},
+			"1.0.1": {"dist": {"tarball": "https://registry.example.org/@scope/pkg/-/pkg-1.0.1.tgz"}, "deprecated": "Broken"},
+			"2.0.0-beta.1": {"dist": {"tarball": "https://registry.example.org/@scope/pkg/-/pkg-2.0.0-beta.1.tgz"}}
+		}}`)
+	})
+
+	server := httptest.NewServer(mux)
+	defer server.Close()
+
+	c := NewCrawler(1)
+
+	h, err := NewNpmHandler(c, server.URL)
+
+	if err != nil {
+		t.Fatal("NewNpmHandler failed:", err)
+	}
+
+	site, _ := url.Parse("https://www.npmjs.com/package/@scope/pkg")
+
+	job := CrawlJob{
+		Port: types.PortInfo{
+			DistVersion: "1.0.0",
+			Config: types.PortConfig{
+				SkipBeta: true,
+			},
+		},
+		Site: site,
+	}
+
+	var result CrawlResult
+
+	err = h.Crawl(context.Background(), job, &result)
+
+	if err != nil {
+		t.Fatal("Crawl failed:", err)
+	}
+
+	expected := map[string]string{
+		"1.0.0": "https://registry.example.org/@scope/pkg/-/pkg-1.0.0.tgz",
+		"1.1.0": "https://registry.example.org/@scope/pkg/-/pkg-1.1.0.tgz",
+	}
+
+	if len(result.Releases) != len(expected) {
+		t.Fatal("Incorrect release count:", result.Releases)
+	}
+
+	for _, release := range result.Releases {
+		if expected[release.Version] != release.File.String() {
+			t.Fatal("Unexpected release:", release.Version, release.File)
+		}
+	}
+
+	if result.Releases[0].Version != "1.0.0" {
+		t.Fatal("Releases not in version order:", result.Releases)
+	}
+
+	job.Site, _ = url.Parse("https://www.npmjs.com/package/")
+	result = CrawlResult{}
+
+	err = h.Crawl(context.Background(), job, &result)
+
+	if err == nil || !strings.Contains(err.Error(), "Not an npm package URL") {
+		t.Fatal("Expected error for no package name; got:", err)
+	}
+
+	job.Site, _ = url.Parse("https://www.npmjs.com/search?q=pkg")
+	result = CrawlResult{}
+
+	err = h.Crawl(context.Background(), job, &result)
+
+	if err == nil || !strings.Contains(err.Error(), "Not an npm package URL") {
+		t.Fatal("Expected error for search page; got:", err)
+	}
+
+	job.Site, _ = url.Parse("https://www.npmjs.com/package/missing")
+	result = CrawlResult{}
+
+	err = h.Crawl(context.Background(), job, &result)
+
+	var statusErr *StatusError
+
+	if !errors.As(err, &statusErr) || statusErr.Code != http.StatusNotFound {
+		t.Fatal("Expected not found error for unknown package; got:", err)
+	}
+
+	job.Site, _ = url.Parse("https://www.npmjs.com/package/empty")
+	result = CrawlResult{}
+
+	err = h.Crawl(context.Background(), job, &result)
+
+	if err != nil {
+		t.Fatal("Crawl failed:", err)
+	}
+
+	if len(result.Releases) != 0 {
+		t.Fatal("Expected no releases for package without versions:", result.Releases)
+	}
+}
@@ -0,0 +1,171 @@
+package crawler
+
+import (
+	"context"
+	"fmt"
+	"maps"
+	"net/url"
+	"regexp"
+	"slices"
+	"strings"
+
+	"github.com/samott/portscout2/version"
+)
+
+const pyPIDefaultIndexUrl = "https://pypi.org/pypi"
+
+// PEP 440 pre-releases ("1.0a1", "1.0rc2") and development releases
+var pyPIPreRegex = regexp.MustCompile(`(?i)[0-9.\-_](?:a|b|c|rc|alpha|beta|pre|preview|dev)[0-9]*(?:$|[.\-_+])`)
+
+// Runs of separators, which PEP 503 treats as equivalent.
+var pyPISeparatorRegex = regexp.MustCompile(`[-_.]+`)
+
+/**
+ * Handles master sites on PyPI's file host, which has no listings.
+ * MASTER_SITES=PYPI expands to
+ * https://files.pythonhosted.org/packages/source/f/foo/, giving the
+ * project name, which is looked up with PyPI's JSON API. Other
+ * paths are crawled as ordinary HTTP sites.
+ */
+type PyPIHandler struct {
+	crawler  *Crawler
+	indexUrl *url.URL
+}
+
+type pyPIProject struct {
+	Releases map[string][]pyPIFile `json:"releases"`
+}
+
+type pyPIFile struct {
+	Filename    string `json:"filename"`
+	Url         string `json:"url"`
+	PackageType string `json:"packagetype"`
+	Yanked      bool   `json:"yanked"`
+}
+
+/**
+ * Creates a handler for the JSON API of the package index at
+ * indexUrl (https://pypi.org/pypi if empty), under which each
+ * project is described at /<project>/json.
+ */
+func NewPyPIHandler(c *Crawler, indexUrl string) (*PyPIHandler, error) {
+	u, err := parseApiUrl(indexUrl, pyPIDefaultIndexUrl, "PyPI index")
+
+	if err != nil {
+		return nil, err
+	}
+
+	return &PyPIHandler{
+		crawler:  c,
+		indexUrl: u,
+	}, nil
+}
+
+/**
+ * Reports the sdist of each release. Yanked files are ignored, as
+ * are pre-releases if the port skips betas; wheels are no use to
+ * the ports tree, so releases with only wheels are ignored too.
+ */
+func (h *PyPIHandler) Crawl(ctx context.Context, job CrawlJob, result *CrawlResult) error {
+	name, ok := pyPIProjectName(job.Site)
+
+	if !ok {
+		// Hashed paths of individual files, for instance
+		return h.crawler.crawlHttp(ctx, job, result)
+	}
+
+	var project pyPIProject
+
+	_, err := h.crawler.fetchJson(ctx, h.indexUrl.JoinPath(name, "json"), nil, &project)
+
+	if err != nil {
+		return fmt.Errorf("PyPI query failed: %w", err)
+	}
+
+	// Oldest first, rather than in random map order
+	vers := slices.Sorted(maps.Keys(project.Releases))
+	slices.SortStableFunc(vers, version.Compare)
+
+	for _, ver := range vers {
+		files := project.Releases[ver]
+
+		if job.Port.Config.SkipBeta && isPyPIPreRelease(ver) {
+			continue
+		}
+
+		file := pyPISdist(job, ver, files)
+
+		if file == nil {
+			continue
+		}
+
+		u, err := url.Parse(file.Url)
+
+		if err != nil {
+			continue
+		}
+
+		result.Releases = append(result.Releases, Release{
+			Version: ver,
+			File:    u,
+		})
+	}
+
+	return nil
+}
+
+// Extracts the project name from .../packages/source/f/foo/,
+// which may be followed by a subdirectory.
+func pyPIProjectName(site *url.URL) (string, bool) {
+	segments := strings.Split(strings.Trim(site.Path, "/"), "/")
+
+	if len(segments) < 4 || segments[0] != "packages" || segments[1] != "source" {
+		return "", false
+	}
+
+	return segments[3], segments[3] != ""
+}
+
+/**
+ * Picks the sdist for a release which is named like the port's
+ * distfile. Failing an exact match, the project part of the name
+ * is compared after PEP 503 normalisation, since newer sdists are
+ * named "foo_bar-1.2.tar.gz" where older ones were "Foo-Bar-1.1.tar.gz".
+ */
+func pyPISdist(job CrawlJob, ver string, files []pyPIFile) *pyPIFile {
+	current := job.Port.DistVersion
+	stem, suffix, found := strings.Cut(job.File, current)
+
+	var similar *pyPIFile
+
+	for i, file := range files {
+		if file.Yanked || file.PackageType != "sdist" {
+			continue
+		}
+
+		if !found || current == "" {
+			// Nothing to go on
+			return &files[i]
+		}
+
+		if file.Filename == stem+ver+suffix {
+			return &files[i]
+		}
+
+		fileStem, fileSuffix, ok := strings.Cut(file.Filename, ver)
+
+		if similar == nil && ok && fileSuffix == suffix && normalisePyPIName(fileStem) == normalisePyPIName(stem) {
+			similar = &files[i]
+		}
+	}
+
+	return similar
+}
+
+func normalisePyPIName(name string) string {
+	return strings.ToLower(pyPISeparatorRegex.ReplaceAllString(name, "-"))
+}
+
+func isPyPIPreRelease(ver string) bool {
+	return pyPIPreRegex.MatchString(ver)
+}
@@ -0,0 +1,188 @@
+package crawler
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+
+	"github.com/samott/portscout2/types"
+)
+
+func TestPyPIHandler(t *testing.T) {
+	mux := http.NewServeMux()
+
+	mux.HandleFunc("GET /pypi/Foo-Bar/json", func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, `{"releases": {
+			"1.1": [
+				{"filename": "Foo-Bar-1.1.tar.gz", "url": "https://files.example.org/Foo-Bar-1.1.tar.gz", "packagetype": "sdist", "yanked": false},
+				{"filename": "Foo_Bar-1.1-py3-none-any.whl", "url": "https://files.example.org/Foo_Bar-1.1-py3-none-any.whl", "packagetype": "bdist_wheel", "yanked": false}
+			],
+			"1.2": [
+				{"filename": "foo_bar-1.2.zip", "url": "https://files.example.org/foo_bar-1.2.zip", "packagetype": "sdist", "yanked": false},
+				{"filename": "foo_bar-1.2.tar.gz", "url": "https://files.example.org/foo_bar-1.2.tar.gz", "packagetype": "sdist", "yanked": false}
+			],
+			"1.3": [
+				{"filename": "foo_bar-1.3.tar.gz", "url": "https://files.example.org/foo_bar-1.3.tar.gz", "packagetype": "sdist", "yanked": true}
+			],
+			"1.4": [
+				{"filename": "foo_bar-1.4-py3-none-any.whl", "url": "https://files.example.org/foo_bar-1.4-py3-none-any.whl", "packagetype": "bdist_wheel", "yanked": false}
+			],
+			"2.0rc1": [
+				{"filename": "foo_bar-2.0rc1.tar.gz", "url": "https://files.example.org/foo_bar-2.0rc1.tar.gz", "packagetype": "sdist", "yanked": false}
+			]
+		}}`)
+	})
+
+	mux.HandleFunc("GET /pypi/wheels-only/json", func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, `{"releases": {
+			"1.0": [
+				{"filename": "wheels_only-1.0-py3-none-any.whl", "url": "https://files.example.org/wheels_only-1.0-py3-none-any.whl", "packagetype": "bdist_wheel", "yanked": false}
+			]
+		}}`)
+	})
+
+	mux.HandleFunc("GET /pypi/empty/json", func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, `{"releases": {}}`)
+	})
+
+	mux.HandleFunc("GET /packages/3f/ab/", func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, `<a href="Foo-Bar-1.1.tar.gz">Foo-Bar-1.1.tar.gz</a>`)
+	})
+
+	server := httptest.NewServer(mux)
+	defer server.Close()
+
+	c := NewCrawler(1)
+
+	h, err := NewPyPIHandler(c, server.URL+"/pypi")
+
+	if err != nil {
+		t.Fatal("NewPyPIHandler failed:", err)
+	}
+
+	site, _ := url.Parse("https://files.pythonhosted.org/packages/source/F/Foo-Bar/")
+
+	job := CrawlJob{
+		Port: types.PortInfo{
+			DistVersion: "1.1",
+			Config: types.PortConfig{
+				SkipBeta: true,
+			},
+		},
+		Site: site,
+		File: "Foo-Bar-1.1.tar.gz",
+	}
+
+	var result CrawlResult
+
+	err = h.Crawl(context.Background(), job, &result)
+
+	if err != nil {
+		t.Fatal("Crawl failed:", err)
+	}
+
+	expected := map[string]string{
+		"1.1": "https://files.example.org/Foo-Bar-1.1.tar.gz",
+		"1.2": "https://files.example.org/foo_bar-1.2.tar.gz",
+	}
+
+	if len(result.Releases) != len(expected) {
+		t.Fatal("Incorrect release count:", result.Releases)
+	}
+
+	for _, release := range result.Releases {
+		if expected[release.Version] != release.File.String() {
+			t.Fatal("Unexpected release:", release.Version, release.File)
+		}
+	}
+
+	if result.Releases[0].Version != "1.1" {
+		t.Fatal("Releases not in version order:", result.Releases)
+	}
+
+	// Not a source directory, so listed like any other site
+	job.Site, _ = url.Parse(server.URL + "/packages/3f/ab/")
+	result = CrawlResult{}
+
+	err = h.Crawl(context.Background(), job, &result)
+
+	if err != nil {
+		t.Fatal("Crawl failed:", err)
+	}
+
+	if len(result.Files) != 1 || result.Files[0].String() != server.URL+"/packages/3f/ab/Foo-Bar-1.1.tar.gz" {
+		t.Fatal("Site not crawled as HTTP:", result.Files)
+	}
+
+	job.Site, _ = url.Parse("https://files.pythonhosted.org/packages/source/F/Foo-Bar/extra/")
+	result = CrawlResult{}
+
+	err = h.Crawl(context.Background(), job, &result)
+
+	if err != nil {
+		t.Fatal("Crawl failed:", err)
+	}
+
+	if len(result.Releases) != len(expected) {
+		t.Fatal("Incorrect release count for subdirectory:", result.Releases)
+	}
+
+	job.Site, _ = url.Parse("https://files.pythonhosted.org/packages/source/m/missing/")
+	result = CrawlResult{}
+
+	err = h.Crawl(context.Background(), job, &result)
+
+	var statusErr *StatusError
+
+	if !errors.As(err, &statusErr) || statusErr.Code != http.StatusNotFound {
+		t.Fatal("Expected not found error for unknown project; got:", err)
+	}
+
+	job.Site, _ = url.Parse("https://files.pythonhosted.org/packages/source/w/wheels-only/")
+	result = CrawlResult{}
+
+	err = h.Crawl(context.Background(), job, &result)
+
+	if err != nil {
+		t.Fatal("Crawl failed:", err)
+	}
+
+	if len(result.Releases) != 0 {
+		t.Fatal("Expected wheels to be ignored:", result.Releases)
+	}
+
+	job.Site, _ = url.Parse("https://files.pythonhosted.org/packages/source/e/empty/")
+	result = CrawlResult{}
+
+	err = h.Crawl(context.Background(), job, &result)
+
+	if err != nil {
+		t.Fatal("Crawl failed:", err)
+	}
+
+	if len(result.Releases) != 0 {
+		t.Fatal("Expected no releases for empty project:", result.Releases)
+	}
+}
+
+func TestIsPyPIPreRelease(t *testing.T) {
+	tests := map[string]bool{
+		"1.0":       false,
+		"1.0.post1": false,
+		"1.0a1":     true,
+		"1.0b2":     true,
+		"1.0rc1":    true,
+		"1.0.dev3":  true,
+		"2024.1":    false,
+	}
+
+	for input, expected := range tests {
+		if isPyPIPreRelease(input) != expected {
+			t.Fatal("Incorrect pre-release check for", input)
+		}
+	}
+}
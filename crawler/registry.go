@@ -0,0 +1,90 @@
+package crawler
+
+import (
+	"context"
+	"net/url"
+	"path"
+	"strings"
+)
+
+// Handler crawls a single site on behalf of a job, filling in the
+// file list of the result. Any error returned is reported in the
+// result's Err field.
+type Handler interface {
+	Crawl(ctx context.Context, job CrawlJob, result *CrawlResult) error
+}
+
+// HandlerFunc adapts an ordinary function to the Handler interface.
+type HandlerFunc func(ctx context.Context, job CrawlJob, result *CrawlResult) error
+
+func (f HandlerFunc) Crawl(ctx context.Context, job CrawlJob, result *CrawlResult) error {
+	return f(ctx, job, result)
+}
+
+type handlerEntry struct {
+	scheme  string
+	host    string
+	handler Handler
+}
+
+/**
+ * Registers a handler for sites with the given URL scheme. If
+ * hostPattern is non-empty, the handler only applies to sites
+ * whose hostname matches it (using path.Match syntax, so e.g.
+ * "*.example.net" is permitted).
+ *
+ * Host-specific handlers take precedence over scheme-wide ones.
+ * Registering a second handler for the same scheme and pattern
+ * replaces the first.
+ */
+func (c *Crawler) RegisterHandler(scheme string, hostPattern string, handler Handler) {
+	c.handlersMu.Lock()
+	defer c.handlersMu.Unlock()
+
+	scheme = strings.ToLower(scheme)
+	hostPattern = strings.ToLower(hostPattern)
+
+	for i, entry := range c.handlers {
+		if entry.scheme == scheme && entry.host == hostPattern {
+			c.handlers[i].handler = handler
+			return
+		}
+	}
+
+	c.handlers = append(c.handlers, handlerEntry{
+		scheme:  scheme,
+		host:    hostPattern,
+		handler: handler,
+	})
+}
+
+func (c *Crawler) handlerFor(site *url.URL) Handler {
+	c.handlersMu.RLock()
+	defer c.handlersMu.RUnlock()
+
+	if site == nil {
+		return nil
+	}
+
+	scheme := strings.ToLower(site.Scheme)
+	host := strings.ToLower(site.Hostname())
+
+	var fallback Handler
+
+	for _, entry := range c.handlers {
+		if entry.scheme != scheme {
+			continue
+		}
+
+		if entry.host == "" {
+			fallback = entry.handler
+			continue
+		}
+
+		if ok, _ := path.Match(entry.host, host); ok {
+			return entry.handler
+		}
+	}
+
+	return fallback
+}
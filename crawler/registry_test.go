@@ -0,0 +1,81 @@
+package crawler
+
+import (
+	"context"
+	"net/url"
+	"testing"
+
+	"github.com/samott/portscout2/types"
+)
+
+type namedHandler struct {
+	name string
+}
+
+func (h *namedHandler) Crawl(ctx context.Context, job CrawlJob, result *CrawlResult) error {
+	return nil
+}
+
+func TestHandlerFor(t *testing.T) {
+	c := NewCrawler(1)
+
+	c.RegisterHandler("https", "*.example.net", &namedHandler{"example"})
+	c.RegisterHandler("gopher", "", &namedHandler{"gopher"})
+
+	cases := []struct {
+		site     string
+		expected string
+	}{
+		{"https://www.example.net/pub/", "example"},
+		{"https://WWW.EXAMPLE.NET/pub/", "example"},
+		{"gopher://gopher.example.org/", "gopher"},
+		{"https://www.example.org/pub/", ""},
+		{"http://www.example.net/pub/", ""},
+	}
+
+	for _, tc := range cases {
+		site, _ := url.Parse(tc.site)
+
+		handler := c.handlerFor(site)
+
+		if handler == nil {
+			t.Fatal("No handler found for", tc.site)
+		}
+
+		// Built-in handlers are reported with an empty name
+		name := ""
+
+		if h, ok := handler.(*namedHandler); ok {
+			name = h.name
+		}
+
+		if name != tc.expected {
+			t.Fatal("Incorrect handler for", tc.site, "got", name)
+		}
+	}
+
+	site, _ := url.Parse("rsync://rsync.example.net/pub/")
+
+	if c.handlerFor(site) != nil {
+		t.Fatal("Unexpected handler for unknown scheme")
+	}
+}
+
+func TestRunUnhandledScheme(t *testing.T) {
+	c := NewCrawler(1)
+	site, _ := url.Parse("rsync://rsync.example.net/pub/")
+
+	go c.Run(context.Background())
+
+	c.In() <- CrawlJob{
+		Port: types.PortInfo{Name: types.PortName{Category: "cat", Name: "test"}},
+		Site: site,
+	}
+	close(c.In())
+
+	result := <-c.Out()
+
+	if result.Err == nil {
+		t.Fatal("Expected error for unhandled scheme")
+	}
+}
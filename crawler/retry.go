@@ -0,0 +1,239 @@
+package crawler
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"math/rand/v2"
+	"net"
+	"net/http"
+	"net/textproto"
+	"strconv"
+	"time"
+)
+
+type ErrorClass int
+
+const (
+	// Errors which won't go away by trying again, such as a 404
+	ErrorPermanent ErrorClass = iota
+	// Network errors, timeouts and server errors
+	ErrorTransient
+	// The server asked us to slow down (HTTP 429, FTP 421)
+	ErrorThrottled
+)
+
+// MaxAttempts includes the first attempt, so 1 means no retries.
+type RetryPolicy struct {
+	MaxAttempts int
+	BaseDelay   time.Duration
+	MaxDelay    time.Duration
+}
+
+var defaultRetryPolicies = map[ErrorClass]RetryPolicy{
+	ErrorTransient: {MaxAttempts: 3, BaseDelay: time.Second, MaxDelay: 30 * time.Second},
+	ErrorThrottled: {MaxAttempts: 3, BaseDelay: 5 * time.Second, MaxDelay: 2 * time.Minute},
+}
+
+// Returned for unsuccessful HTTP responses.
+type StatusError struct {
+	Code       int
+	Status     string
+	RetryAfter time.Duration
+}
+
+func (e *StatusError) Error() string {
+	return "Request not successful: " + e.Status
+}
+
+func newStatusError(resp *http.Response) *StatusError {
+	return &StatusError{
+		Code:       resp.StatusCode,
+		Status:     resp.Status,
+		RetryAfter: parseRetryAfter(resp.Header.Get("Retry-After"), time.Now()),
+	}
+}
+
+/**
+ * Parses a Retry-After header, which is either a number of seconds
+ * or an HTTP date. Returns zero if the header is missing, invalid
+ * or already in the past.
+ */
+func parseRetryAfter(value string, now time.Time) time.Duration {
+	if value == "" {
+		return 0
+	}
+
+	if secs, err := strconv.Atoi(value); err == nil {
+		return max(time.Duration(secs)*time.Second, 0)
+	}
+
+	if date, err := http.ParseTime(value); err == nil {
+		return max(date.Sub(now), 0)
+	}
+
+	return 0
+}
+
+/**
+ * Works out whether an error is worth retrying, and how long the
+ * server asked us to wait if it said.
+ */
+func classifyError(err error) (ErrorClass, time.Duration) {
+	if errors.Is(err, context.Canceled) {
+		return ErrorPermanent, 0
+	}
+
+	var statusErr *StatusError
+
+	if errors.As(err, &statusErr) {
+		switch statusErr.Code {
+		case http.StatusTooManyRequests:
+			return ErrorThrottled, statusErr.RetryAfter
+		case http.StatusServiceUnavailable:
+			if statusErr.RetryAfter > 0 {
+				return ErrorThrottled, statusErr.RetryAfter
+			}
+			return ErrorTransient, 0
+		case http.StatusInternalServerError, http.StatusBadGateway, http.StatusGatewayTimeout:
+			return ErrorTransient, 0
+		default:
+			return ErrorPermanent, 0
+		}
+	}
+
+	var protoErr *textproto.Error
+
+	if errors.As(err, &protoErr) {
+		switch {
+		case protoErr.Code == 421:
+			// Too many users, or similar
+			return ErrorThrottled, 0
+		case protoErr.Code >= 400 && protoErr.Code <= 499:
+			return ErrorTransient, 0
+		default:
+			return ErrorPermanent, 0
+		}
+	}
+
+	var netErr net.Error
+
+	if errors.As(err, &netErr) || errors.Is(err, context.DeadlineExceeded) || errors.Is(err, io.ErrUnexpectedEOF) {
+		return ErrorTransient, 0
+	}
+
+	return ErrorPermanent, 0
+}
+
+/**
+ * Sets how errors of the given class are retried. Permanent errors
+ * are never retried. A zero BaseDelay or MaxDelay leaves the
+ * default for the class, rather than retrying immediately or
+ * capping every delay at nothing.
+ */
+func (c *Crawler) SetRetryPolicy(class ErrorClass, policy RetryPolicy) {
+	if policy.BaseDelay == 0 {
+		policy.BaseDelay = defaultRetryPolicies[class].BaseDelay
+	}
+
+	if policy.MaxDelay == 0 {
+		policy.MaxDelay = defaultRetryPolicies[class].MaxDelay
+	}
+
+	c.retryPolicies[class] = policy
+}
+
+/**
+ * Crawls a single site with the given handler, retrying transient
+ * and throttled failures according to the retry policy for their
+ * class. Each attempt gets a fresh result and the full site
+ * timeout, though a site which hits that timeout isn't retried.
+ * The limiter is told to back off the host before each
+ * retry, so other jobs for the same host slow down too.
+ */
+func (c *Crawler) crawlWithRetry(ctx context.Context, handler Handler, job CrawlJob) CrawlResult {
+	for attempt := 1; ; attempt++ {
+		result := CrawlResult{
+			Port: job.Port.Name,
+			Site: job.Site,
+		}
+
+		siteCtx, cancel := context.WithTimeout(ctx, c.siteTimeout)
+		result.Err = handler.Crawl(siteCtx, job, &result)
+		timedOut := siteCtx.Err() != nil
+		cancel()
+
+		// A site which used up its whole allowance is better
+		// skipped in favour of the next mirror
+		if result.Err == nil || ctx.Err() != nil || timedOut {
+			return result
+		}
+
+		class, retryAfter := classifyError(result.Err)
+		policy, ok := c.retryPolicies[class]
+
+		if class == ErrorPermanent || !ok || attempt >= policy.MaxAttempts {
+			return result
+		}
+
+		delay := max(backoffDelay(policy, attempt), retryAfter)
+
+		if delay > policy.MaxDelay {
+			// Not prepared to wait that long; let the next mirror have a go
+			if c.limiter != nil {
+				c.limiter.Backoff(job.Site, retryAfter)
+			}
+
+			return result
+		}
+
+		slog.Debug("Retrying crawl", "port", job.Port.Name, "site", job.Site.String(), "attempt", attempt, "delay", delay, "err", result.Err)
+
+		if c.limiter != nil {
+			c.limiter.Backoff(job.Site, delay)
+		}
+
+		if err := sleepContext(ctx, delay); err != nil {
+			result.Err = err
+			return result
+		}
+	}
+}
+
+/**
+ * Returns the delay before the given retry: exponential in the
+ * number of attempts so far, capped at the policy maximum, with
+ * "equal jitter" so that the delay is at least half the nominal
+ * value and crawls of the same host spread out.
+ */
+func backoffDelay(policy RetryPolicy, attempt int) time.Duration {
+	delay := policy.BaseDelay
+
+	for i := 1; i < attempt && delay < policy.MaxDelay; i++ {
+		delay *= 2
+	}
+
+	delay = min(delay, policy.MaxDelay)
+
+	if delay <= 0 {
+		return 0
+	}
+
+	half := delay / 2
+
+	return half + rand.N(delay-half+1)
+}
+
+func sleepContext(ctx context.Context, d time.Duration) error {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		return fmt.Errorf("Retry abandoned: %w", ctx.Err())
+	}
+}
@@ -0,0 +1,214 @@
+package crawler
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/textproto"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/samott/portscout2/types"
+)
+
+func TestClassifyError(t *testing.T) {
+	cases := []struct {
+		err        error
+		class      ErrorClass
+		retryAfter time.Duration
+	}{
+		{&StatusError{Code: 404}, ErrorPermanent, 0},
+		{&StatusError{Code: 502}, ErrorTransient, 0},
+		{&StatusError{Code: 503}, ErrorTransient, 0},
+		{&StatusError{Code: 503, RetryAfter: time.Minute}, ErrorThrottled, time.Minute},
+		{&StatusError{Code: 429, RetryAfter: time.Second}, ErrorThrottled, time.Second},
+		{fmt.Errorf("FTP dial failed: %w", &textproto.Error{Code: 421}), ErrorThrottled, 0},
+		{fmt.Errorf("FTP list failed: %w", &textproto.Error{Code: 450}), ErrorTransient, 0},
+		{fmt.Errorf("FTP cwd failed: %w", &textproto.Error{Code: 550}), ErrorPermanent, 0},
+		{fmt.Errorf("Error making request: %w", context.DeadlineExceeded), ErrorTransient, 0},
+		{context.Canceled, ErrorPermanent, 0},
+		{errors.New("Unhandled site scheme or format"), ErrorPermanent, 0},
+	}
+
+	for _, tc := range cases {
+		class, retryAfter := classifyError(tc.err)
+
+		if class != tc.class || retryAfter != tc.retryAfter {
+			t.Fatal("Incorrect classification for", tc.err, "got", class, retryAfter)
+		}
+	}
+}
+
+func TestParseRetryAfter(t *testing.T) {
+	now := time.Date(2025, 1, 1, 12, 0, 0, 0, time.UTC)
+
+	cases := []struct {
+		value    string
+		expected time.Duration
+	}{
+		{"", 0},
+		{"120", 2 * time.Minute},
+		{"-5", 0},
+		{"Wed, 01 Jan 2025 12:00:30 GMT", 30 * time.Second},
+		{"Wed, 01 Jan 2025 11:00:00 GMT", 0},
+		{"soon", 0},
+	}
+
+	for _, tc := range cases {
+		if d := parseRetryAfter(tc.value, now); d != tc.expected {
+			t.Fatal("Incorrect delay for", tc.value, "got", d)
+		}
+	}
+}
+
+func TestBackoffDelay(t *testing.T) {
+	policy := RetryPolicy{
+		MaxAttempts: 5,
+		BaseDelay:   time.Second,
+		MaxDelay:    4 * time.Second,
+	}
+
+	cases := []struct {
+		attempt int
+		nominal time.Duration
+	}{
+		{1, time.Second},
+		{2, 2 * time.Second},
+		{3, 4 * time.Second},
+		{4, 4 * time.Second},
+	}
+
+	for _, tc := range cases {
+		for range 20 {
+			d := backoffDelay(policy, tc.attempt)
+
+			if d < tc.nominal/2 || d > tc.nominal {
+				t.Fatal("Delay out of range for attempt", tc.attempt, "got", d)
+			}
+		}
+	}
+}
+
+func TestSetRetryPolicyDefaults(t *testing.T) {
+	c := NewCrawler(1)
+
+	c.SetRetryPolicy(ErrorTransient, RetryPolicy{
+		MaxAttempts: 5,
+		BaseDelay:   time.Second,
+	})
+
+	policy := c.retryPolicies[ErrorTransient]
+
+	if policy.MaxDelay != defaultRetryPolicies[ErrorTransient].MaxDelay {
+		t.Fatal("Default cap not applied:", policy.MaxDelay)
+	}
+
+	if d := backoffDelay(policy, 3); d < 2*time.Second {
+		t.Fatal("Delay capped at zero:", d)
+	}
+
+	c.SetRetryPolicy(ErrorTransient, RetryPolicy{
+		MaxAttempts: 5,
+	})
+
+	policy = c.retryPolicies[ErrorTransient]
+
+	if policy.BaseDelay != defaultRetryPolicies[ErrorTransient].BaseDelay {
+		t.Fatal("Default base delay not applied:", policy.BaseDelay)
+	}
+}
+
+type fakeLimiter struct {
+	backoffs  []time.Duration
+	throttled int
+	inFlight  int
+}
+
+func (l *fakeLimiter) Wait(site *url.URL, ctx context.Context) error {
+	l.inFlight++
+	return ctx.Err()
+}
+
+func (l *fakeLimiter) Done(site *url.URL, latency time.Duration, throttled bool) {
+	l.inFlight--
+
+	if throttled {
+		l.throttled++
+	}
+}
+
+func (l *fakeLimiter) SetMinInterval(site *url.URL, d time.Duration) {
+}
+
+func (l *fakeLimiter) Backoff(site *url.URL, d time.Duration) {
+	l.backoffs = append(l.backoffs, d)
+}
+
+func TestCrawlRetry(t *testing.T) {
+	requests := 0
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/dl/" {
+			http.NotFound(w, r)
+			return
+		}
+
+		requests++
+
+		switch requests {
+		case 1:
+			w.Header().Set("Retry-After", "0")
+			http.Error(w, "slow down", http.StatusTooManyRequests)
+		case 2:
+			http.Error(w, "bad gateway", http.StatusBadGateway)
+		default:
+			w.Write([]byte(`<a href="foo-1.1.tar.gz">foo-1.1.tar.gz</a>`))
+		}
+	}))
+	defer server.Close()
+
+	site, _ := url.Parse(server.URL + "/dl/")
+
+	c := NewCrawler(1)
+	limiter := &fakeLimiter{}
+
+	c.SetLimiter(limiter)
+	c.SetRetryPolicy(ErrorTransient, RetryPolicy{MaxAttempts: 3, BaseDelay: time.Millisecond, MaxDelay: 10 * time.Millisecond})
+	c.SetRetryPolicy(ErrorThrottled, RetryPolicy{MaxAttempts: 3, BaseDelay: time.Millisecond, MaxDelay: 10 * time.Millisecond})
+
+	job := CrawlJob{
+		Port: types.PortInfo{Name: types.PortName{Category: "cat", Name: "foo"}},
+		Site: site,
+		File: "foo-1.0.tar.gz",
+	}
+
+	result := c.crawl(context.Background(), job)
+
+	if result.Err != nil {
+		t.Fatal("Crawl failed:", result.Err)
+	}
+
+	if requests != 3 || len(limiter.backoffs) != 2 {
+		t.Fatal("Incorrect retry count:", requests, len(limiter.backoffs))
+	}
+
+	if len(result.Files) != 1 {
+		t.Fatal("Incorrect files:", result.Files)
+	}
+
+	if limiter.inFlight != 0 || limiter.throttled != 1 {
+		t.Fatal("Limiter not told about outcomes:", limiter.inFlight, limiter.throttled)
+	}
+
+	// Not found isn't worth retrying
+	job.Site, _ = url.Parse(server.URL + "/missing/")
+
+	result = c.crawl(context.Background(), job)
+
+	if result.Err == nil || len(limiter.backoffs) != 2 {
+		t.Fatal("Permanent error retried:", result.Err, len(limiter.backoffs))
+	}
+}
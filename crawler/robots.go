@@ -0,0 +1,377 @@
+package crawler
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"path"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Our product token, as matched against User-agent lines.
+const robotsAgent = "portscout"
+
+// Only this much of a robots.txt file is read, as per RFC 9309.
+const maxRobotsSize = 500 * 1024
+
+var ErrRobotsDisallowed = errors.New("Disallowed by robots.txt")
+
+type robotsPolicy struct {
+	mu      sync.Mutex
+	enabled bool
+	expiry  time.Duration
+	ignore  []string
+	entries map[string]*robotsEntry
+
+	// How long a server error is kept before robots.txt is retried
+	unavailableExpiry time.Duration
+}
+
+type robotsEntry struct {
+	done    chan struct{}
+	rules   *robotsRules
+	expires time.Time
+	err     error
+}
+
+// The rules from the group of a robots.txt file which applies to us.
+type robotsRules struct {
+	rules      []robotsRule
+	crawlDelay time.Duration
+
+	// The server failed to return the file; the rules disallow all
+	unavailable bool
+}
+
+type robotsRule struct {
+	allow   bool
+	pattern string
+	regex   *regexp.Regexp
+}
+
+func newRobotsPolicy() *robotsPolicy {
+	return &robotsPolicy{
+		expiry:            24 * time.Hour,
+		unavailableExpiry: 5 * time.Minute,
+		entries:           make(map[string]*robotsEntry),
+	}
+}
+
+/**
+ * Enables robots.txt compliance for HTTP listings and probes. Each
+ * host's robots.txt is fetched once and kept for the expiry time.
+ * Hosts matching one of the ignore patterns (path.Match syntax, as
+ * for handlers), e.g. because they have given us permission, are
+ * crawled regardless.
+ */
+func (c *Crawler) SetRobots(enabled bool, expiry time.Duration, ignore []string) {
+	c.robots.mu.Lock()
+	defer c.robots.mu.Unlock()
+
+	c.robots.enabled = enabled
+
+	if expiry > 0 {
+		c.robots.expiry = expiry
+	}
+
+	c.robots.ignore = make([]string, 0, len(ignore))
+
+	for _, pattern := range ignore {
+		c.robots.ignore = append(c.robots.ignore, strings.ToLower(pattern))
+	}
+}
+
+/**
+ * Checks whether robots.txt allows us to fetch the given URL,
+ * returning ErrRobotsDisallowed if not. A Crawl-delay is passed on
+ * to the limiter for the host.
+ */
+func (c *Crawler) checkRobots(ctx context.Context, target *url.URL) error {
+	if !c.robots.applies(target) {
+		return nil
+	}
+
+	rules, err := c.robots.get(ctx, target, c.fetchRobots)
+
+	if err != nil {
+		return err
+	}
+
+	if rules.crawlDelay > 0 && c.limiter != nil {
+		c.limiter.SetMinInterval(target, rules.crawlDelay)
+	}
+
+	if !rules.allowed(target) {
+		return fmt.Errorf("%w: %s", ErrRobotsDisallowed, target)
+	}
+
+	return nil
+}
+
+func (p *robotsPolicy) applies(target *url.URL) bool {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if !p.enabled {
+		return false
+	}
+
+	host := strings.ToLower(target.Hostname())
+
+	for _, pattern := range p.ignore {
+		if matched, _ := path.Match(pattern, host); matched {
+			return false
+		}
+	}
+
+	return true
+}
+
+/**
+ * Returns the cached rules for the target's host, fetching them if
+ * they're missing or expired. Concurrent requests for the same host
+ * share one fetch.
+ */
+func (p *robotsPolicy) get(ctx context.Context, target *url.URL, fetch func(context.Context, *url.URL) (*robotsRules, error)) (*robotsRules, error) {
+	key := strings.ToLower(target.Scheme + "://" + target.Host)
+
+	p.mu.Lock()
+
+	entry, ok := p.entries[key]
+
+	if ok {
+		select {
+		case <-entry.done:
+			if time.Now().Before(entry.expires) {
+				p.mu.Unlock()
+				return entry.rules, nil
+			}
+			ok = false
+		default:
+		}
+	}
+
+	if ok {
+		p.mu.Unlock()
+
+		select {
+		case <-entry.done:
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+
+		return entry.rules, entry.err
+	}
+
+	entry = &robotsEntry{
+		done: make(chan struct{}),
+	}
+
+	p.entries[key] = entry
+
+	p.mu.Unlock()
+
+	entry.rules, entry.err = fetch(ctx, target)
+	entry.expires = time.Now().Add(p.expiry)
+
+	if entry.rules != nil && entry.rules.unavailable {
+		// Server errors are usually temporary; don't hold the host
+		// to a complete disallow for the full expiry time
+		entry.expires = time.Now().Add(p.unavailableExpiry)
+	}
+
+	if entry.err != nil {
+		// Try again next time
+		p.mu.Lock()
+		delete(p.entries, key)
+		p.mu.Unlock()
+	}
+
+	close(entry.done)
+
+	return entry.rules, entry.err
+}
+
+/**
+ * Fetches and parses the robots.txt file for the target's host.
+ * Following RFC 9309, a missing file (any 4xx) allows everything
+ * and a server error disallows everything, until it is retried.
+ */
+func (c *Crawler) fetchRobots(ctx context.Context, target *url.URL) (*robotsRules, error) {
+	robots := &url.URL{
+		Scheme: target.Scheme,
+		Host:   target.Host,
+		Path:   "/robots.txt",
+	}
+
+	req, err := http.NewRequestWithContext(ctx, "GET", robots.String(), nil)
+
+	if err != nil {
+		return nil, fmt.Errorf("Error creating request: %w", err)
+	}
+
+	req.Header.Set("User-Agent", "portscout/2")
+
+	resp, err := c.httpClient().Do(req)
+
+	if err != nil {
+		return nil, fmt.Errorf("Error fetching robots.txt: %w", err)
+	}
+
+	defer resp.Body.Close()
+
+	switch {
+	case resp.StatusCode >= 200 && resp.StatusCode <= 299:
+		return parseRobots(io.LimitReader(resp.Body, maxRobotsSize)), nil
+	case resp.StatusCode >= 400 && resp.StatusCode <= 499:
+		return &robotsRules{}, nil
+	default:
+		slog.Debug("robots.txt unavailable; assuming complete disallow", "site", robots.String(), "status", resp.Status)
+		return &robotsRules{
+			rules: []robotsRule{
+				newRobotsRule(false, "/"),
+			},
+			unavailable: true,
+		}, nil
+	}
+}
+
+/**
+ * Parses a robots.txt file, keeping only the rules which apply to
+ * us: those of the groups naming our product token, or failing
+ * that those of the "*" groups.
+ */
+func parseRobots(r io.Reader) *robotsRules {
+	ours := &robotsRules{}
+	wildcard := &robotsRules{}
+	foundOurs := false
+
+	var agents []string
+
+	inRules := false
+	scanner := bufio.NewScanner(r)
+
+	for scanner.Scan() {
+		line, _, _ := strings.Cut(scanner.Text(), "#")
+		key, value, found := strings.Cut(line, ":")
+
+		if !found {
+			continue
+		}
+
+		key = strings.ToLower(strings.TrimSpace(key))
+		value = strings.TrimSpace(value)
+
+		if key == "user-agent" {
+			if inRules {
+				// A new group starts
+				agents = nil
+				inRules = false
+			}
+
+			agents = append(agents, strings.ToLower(value))
+			continue
+		}
+
+		if key != "allow" && key != "disallow" && key != "crawl-delay" {
+			continue
+		}
+
+		inRules = true
+
+		targets := make([]*robotsRules, 0)
+
+		for _, agent := range agents {
+			switch {
+			case agent == robotsAgent:
+				targets = append(targets, ours)
+				foundOurs = true
+			case agent == "*":
+				targets = append(targets, wildcard)
+			}
+		}
+
+		for _, target := range targets {
+			switch key {
+			case "allow", "disallow":
+				// An empty Disallow means nothing is disallowed
+				if value != "" {
+					target.rules = append(target.rules, newRobotsRule(key == "allow", value))
+				}
+			case "crawl-delay":
+				if secs, err := strconv.ParseFloat(value, 64); err == nil && secs > 0 {
+					target.crawlDelay = time.Duration(secs * float64(time.Second))
+				}
+			}
+		}
+	}
+
+	if foundOurs {
+		return ours
+	}
+
+	return wildcard
+}
+
+// Compiles a rule's path pattern, which may use * and a final $.
+func newRobotsRule(allow bool, pattern string) robotsRule {
+	anchored := strings.HasSuffix(pattern, "$")
+	expr := regexp.QuoteMeta(strings.TrimSuffix(pattern, "$"))
+	expr = "^" + strings.ReplaceAll(expr, `\*`, ".*")
+
+	if anchored {
+		expr += "$"
+	}
+
+	return robotsRule{
+		allow:   allow,
+		pattern: pattern,
+		regex:   regexp.MustCompile(expr),
+	}
+}
+
+/**
+ * Applies the most specific (longest) matching rule to the URL's
+ * path and query; where an Allow and a Disallow are equally
+ * specific, the Allow wins. No matching rule means allowed.
+ */
+func (r *robotsRules) allowed(target *url.URL) bool {
+	if r == nil {
+		return true
+	}
+
+	p := target.EscapedPath()
+
+	if p == "" {
+		p = "/"
+	}
+
+	if target.RawQuery != "" {
+		p += "?" + target.RawQuery
+	}
+
+	allowed := true
+	longest := -1
+
+	for _, rule := range r.rules {
+		if !rule.regex.MatchString(p) {
+			continue
+		}
+
+		if len(rule.pattern) > longest || (len(rule.pattern) == longest && rule.allow) {
+			longest = len(rule.pattern)
+			allowed = rule.allow
+		}
+	}
+
+	return allowed
+}
@@ -0,0 +1,150 @@
+package crawler
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestParseRobots(t *testing.T) {
+	doc := `# Example
+User-agent: *
+Disallow: /
+
+User-agent: Googlebot
+User-agent: portscout
+Disallow: /private/
+Disallow: /*.php$
+Allow: /private/pub/
+Crawl-delay: 2.5
+
+User-agent: other
+Allow: /
+`
+
+	rules := parseRobots(strings.NewReader(doc))
+
+	if rules.crawlDelay != 2500*time.Millisecond {
+		t.Fatal("Incorrect crawl delay:", rules.crawlDelay)
+	}
+
+	cases := []struct {
+		target  string
+		allowed bool
+	}{
+		{"http://www.example.net/pub/foo/", true},
+		{"http://www.example.net/private/foo/", false},
+		{"http://www.example.net/private/pub/foo-1.0.tar.gz", true},
+		{"http://www.example.net/index.php", false},
+		{"http://www.example.net/index.php?dir=foo", true},
+		{"http://www.example.net", true},
+	}
+
+	for _, tc := range cases {
+		target, _ := url.Parse(tc.target)
+
+		if rules.allowed(target) != tc.allowed {
+			t.Fatal("Incorrect decision for", tc.target)
+		}
+	}
+
+	wildcard := parseRobots(strings.NewReader("User-agent: *\nDisallow: /pub/\nDisallow:\n"))
+	target, _ := url.Parse("http://www.example.net/pub/foo/")
+
+	if wildcard.allowed(target) {
+		t.Fatal("Wildcard group not applied")
+	}
+}
+
+func TestListHttpRobots(t *testing.T) {
+	robotsFetches := 0
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/robots.txt" {
+			robotsFetches++
+			w.Write([]byte("User-agent: *\nDisallow: /private/\n"))
+			return
+		}
+
+		w.Write([]byte(`<a href="foo-1.0.tar.gz">foo-1.0.tar.gz</a>`))
+	}))
+	defer server.Close()
+
+	c := NewCrawler(1)
+
+	c.SetRobots(true, time.Hour, nil)
+
+	public, _ := url.Parse(server.URL + "/pub/")
+	private, _ := url.Parse(server.URL + "/private/")
+
+	if _, err := c.listHttp(context.Background(), public); err != nil {
+		t.Fatal("Allowed listing failed:", err)
+	}
+
+	if _, err := c.listHttp(context.Background(), private); !errors.Is(err, ErrRobotsDisallowed) {
+		t.Fatal("Disallowed listing fetched:", err)
+	}
+
+	if robotsFetches != 1 {
+		t.Fatal("robots.txt not cached:", robotsFetches)
+	}
+
+	c.SetRobots(true, time.Hour, []string{"127.0.0.*"})
+
+	if _, err := c.listHttp(context.Background(), private); err != nil {
+		t.Fatal("Ignored host still subject to robots.txt:", err)
+	}
+}
+
+func TestListHttpRobotsUnavailable(t *testing.T) {
+	robotsFetches := 0
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/robots.txt" {
+			robotsFetches++
+
+			if robotsFetches == 1 {
+				w.WriteHeader(http.StatusServiceUnavailable)
+				return
+			}
+
+			w.Write([]byte("User-agent: *\nDisallow: /private/\n"))
+			return
+		}
+
+		w.Write([]byte(`<a href="foo-1.0.tar.gz">foo-1.0.tar.gz</a>`))
+	}))
+	defer server.Close()
+
+	c := NewCrawler(1)
+
+	c.SetRobots(true, time.Hour, nil)
+
+	public, _ := url.Parse(server.URL + "/pub/")
+
+	if _, err := c.listHttp(context.Background(), public); !errors.Is(err, ErrRobotsDisallowed) {
+		t.Fatal("Listing fetched despite robots.txt server error:", err)
+	}
+
+	entry := c.robots.entries[strings.ToLower("http://"+public.Host)]
+
+	if entry == nil || time.Until(entry.expires) > 10*time.Minute {
+		t.Fatal("robots.txt server error cached for too long")
+	}
+
+	// As though the short expiry had passed
+	entry.expires = time.Now()
+
+	if _, err := c.listHttp(context.Background(), public); err != nil {
+		t.Fatal("Listing failed after robots.txt recovered:", err)
+	}
+
+	if robotsFetches != 2 {
+		t.Fatal("robots.txt not retried:", robotsFetches)
+	}
+}
@@ -0,0 +1,83 @@
+package crawler
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"strings"
+)
+
+const rubyGemsDefaultUrl = "https://rubygems.org"
+
+/**
+ * Crawls gems, planned as https://rubygems.org/gems/<name>. The
+ * versions API lists each release once per platform it was built
+ * for, and only the pure Ruby builds are of use to ports, since
+ * those are the <name>-<version>.gem files they fetch.
+ */
+type RubyGemsHandler struct {
+	crawler *Crawler
+	baseUrl *url.URL
+}
+
+type rubyGemVersion struct {
+	Number     string `json:"number"`
+	Platform   string `json:"platform"`
+	Prerelease bool   `json:"prerelease"`
+}
+
+/**
+ * Creates a handler for the gem server at baseUrl (https://rubygems.org
+ * if empty), which must serve both the versions API under /api/v1
+ * and the gems themselves under /downloads.
+ */
+func NewRubyGemsHandler(c *Crawler, baseUrl string) (*RubyGemsHandler, error) {
+	u, err := parseApiUrl(baseUrl, rubyGemsDefaultUrl, "RubyGems")
+
+	if err != nil {
+		return nil, err
+	}
+
+	return &RubyGemsHandler{
+		crawler: c,
+		baseUrl: u,
+	}, nil
+}
+
+/**
+ * Reports every version of the pure Ruby gem, skipping those built
+ * for specific platforms (which share their version numbers) and,
+ * if the port skips betas, pre-releases.
+ */
+func (h *RubyGemsHandler) Crawl(ctx context.Context, job CrawlJob, result *CrawlResult) error {
+	name, ok := strings.CutPrefix(strings.TrimSuffix(job.Site.Path, "/"), "/gems/")
+
+	if !ok || name == "" || strings.Contains(name, "/") {
+		return fmt.Errorf("Not a gem URL: %s", job.Site)
+	}
+
+	var versions []rubyGemVersion
+
+	_, err := h.crawler.fetchJson(ctx, h.baseUrl.JoinPath("api", "v1", "versions", name+".json"), nil, &versions)
+
+	if err != nil {
+		return fmt.Errorf("RubyGems query failed: %w", err)
+	}
+
+	for _, ver := range versions {
+		if ver.Platform != "" && ver.Platform != "ruby" {
+			continue
+		}
+
+		if ver.Prerelease && job.Port.Config.SkipBeta {
+			continue
+		}
+
+		result.Releases = append(result.Releases, Release{
+			Version: ver.Number,
+			File:    h.baseUrl.JoinPath("downloads", name+"-"+ver.Number+".gem"),
+		})
+	}
+
+	return nil
+}
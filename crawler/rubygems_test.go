@@ -0,0 +1,112 @@
+package crawler
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/samott/portscout2/types"
+)
+
+func TestRubyGemsHandler(t *testing.T) {
+	mux := http.NewServeMux()
+
+	mux.HandleFunc("GET /api/v1/versions/rake.json", func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, `[
+			{"number": "13.2.0", "platform": "ruby", "prerelease": false},
+			{"number": "13.2.0", "platform": "java", "prerelease": false},
+			{"number": "14.0.0.beta1", "platform": "ruby", "prerelease": true},
+			{"number": "13.1.0", "platform": "ruby", "prerelease": false}
+		]`)
+	})
+
+	mux.HandleFunc("GET /api/v1/versions/jruby-only.json", func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, `[{"number": "1.0.0", "platform": "java", "prerelease": false}]`)
+	})
+
+	server := httptest.NewServer(mux)
+	defer server.Close()
+
+	c := NewCrawler(1)
+
+	h, err := NewRubyGemsHandler(c, server.URL)
+
+	if err != nil {
+		t.Fatal("NewRubyGemsHandler failed:", err)
+	}
+
+	site, _ := url.Parse("https://rubygems.org/gems/rake")
+
+	job := CrawlJob{
+		Port: types.PortInfo{
+			DistVersion: "13.1.0",
+			Config: types.PortConfig{
+				SkipBeta: true,
+			},
+		},
+		Site: site,
+	}
+
+	var result CrawlResult
+
+	err = h.Crawl(context.Background(), job, &result)
+
+	if err != nil {
+		t.Fatal("Crawl failed:", err)
+	}
+
+	if len(result.Releases) != 2 {
+		t.Fatal("Incorrect release count:", result.Releases)
+	}
+
+	if release := result.Releases[0]; release.Version != "13.2.0" || release.File.String() != server.URL+"/downloads/rake-13.2.0.gem" {
+		t.Fatal("Unexpected release:", release.Version, release.File)
+	}
+
+	job.Site, _ = url.Parse("https://rubygems.org/gems/")
+	result = CrawlResult{}
+
+	err = h.Crawl(context.Background(), job, &result)
+
+	if err == nil || !strings.Contains(err.Error(), "Not a gem URL") {
+		t.Fatal("Expected error for no gem name; got:", err)
+	}
+
+	job.Site, _ = url.Parse("https://rubygems.org/gems/rake/versions")
+	result = CrawlResult{}
+
+	err = h.Crawl(context.Background(), job, &result)
+
+	if err == nil || !strings.Contains(err.Error(), "Not a gem URL") {
+		t.Fatal("Expected error for versions page; got:", err)
+	}
+
+	job.Site, _ = url.Parse("https://rubygems.org/gems/missing")
+	result = CrawlResult{}
+
+	err = h.Crawl(context.Background(), job, &result)
+
+	var statusErr *StatusError
+
+	if !errors.As(err, &statusErr) || statusErr.Code != http.StatusNotFound {
+		t.Fatal("Expected not found error for unknown gem; got:", err)
+	}
+
+	job.Site, _ = url.Parse("https://rubygems.org/gems/jruby-only")
+	result = CrawlResult{}
+
+	err = h.Crawl(context.Background(), job, &result)
+
+	if err != nil {
+		t.Fatal("Crawl failed:", err)
+	}
+
+	if len(result.Releases) != 0 {
+		t.Fatal("Expected platform builds to be ignored:", result.Releases)
+	}
+}
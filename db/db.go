@@ -21,18 +21,47 @@ type DB struct {
 }
 
 type portEntry struct {
-	Name        string
-	Version     string
-	NewVersion  *string `db:"newVersion"`
-	Category    string
-	CheckedAt   *time.Time `db:"checkedAt"`
-	UpdatedAt   *time.Time `db:"updatedAt"`
-	Portscout   string     `db:"portscout"`
-	Maintainer  string
-	MasterSites string  `db:"masterSites"`
-	DistFiles   string  `db:"distFiles"`
-	GitHub      *string `db:"gitHub"`
-	Config      string  `db:"portConfig"`
+	Name          string
+	Version       string
+	NewVersion    *string `db:"newVersion"`
+	NewFile       *string `db:"newFile"`
+	Category      string
+	CheckedAt     *time.Time `db:"checkedAt"`
+	UpdatedAt     *time.Time `db:"updatedAt"`
+	Portscout     string     `db:"portscout"`
+	Maintainer    string
+	DistName      string  `db:"distName"`
+	ExtractSuffix string  `db:"extractSuffix"`
+	MasterSites   string  `db:"masterSites"`
+	DistFiles     string  `db:"distFiles"`
+	GitHub        *string `db:"gitHub"`
+	Forge         *string `db:"forge"`
+	GoModule      string  `db:"goModule"`
+	Config        string  `db:"portConfig"`
+}
+
+type hostEntry struct {
+	Hostname            string
+	AccessedAt          *time.Time `db:"accessedAt"`
+	IsDown              bool       `db:"isDown"`
+	DownUntil           *time.Time `db:"downUntil"`
+	SuccessCount        uint       `db:"successCount"`
+	FailureCount        uint       `db:"failureCount"`
+	ConsecutiveFailures uint       `db:"consecutiveFailures"`
+	LastError           *string    `db:"lastError"`
+}
+
+type hostRateEntry struct {
+	Hostname      string
+	AccessedAt    *time.Time `db:"accessedAt"`
+	LastRequestAt *time.Time `db:"lastRequestAt"`
+	LearnedRate   *float64   `db:"learnedRate"`
+}
+
+type listingEntry struct {
+	Url          string
+	ETag         *string `db:"etag"`
+	LastModified *string `db:"lastModified"`
 }
 
 func NewDB(dbUrl string) (*DB, error) {
@@ -70,6 +99,17 @@ func (db *DB) UpdatePort(port types.PortInfo) error {
 		github = nil
 	}
 
+	var forge *string
+
+	if port.Forge != nil {
+		forgeBytes, err := json.Marshal(port.Forge)
+		if err != nil {
+			return fmt.Errorf("Unable to marshal Forge field to JSON: %w", err)
+		}
+		forgeString := string(forgeBytes)
+		forge = &forgeString
+	}
+
 	pcbytes, err := json.Marshal(port.Config)
 	if err != nil {
 		return fmt.Errorf("Unable to marshal PortConfig field to JSON: %w", err)
@@ -80,27 +120,38 @@ func (db *DB) UpdatePort(port types.PortInfo) error {
 	distFiles := types.MarshalTaggedLists(port.DistFiles)
 
 	query := db.gdb.Insert("ports").Rows(goqu.Record{
-		"name":        port.Name.Name,
-		"category":    port.Name.Category,
-		"version":     port.DistVersion,
-		"maintainer":  port.Maintainer,
-		"masterSites": masterSites,
-		"distFiles":   distFiles,
-		"gitHub":      github,
-		"portscout":   port.Portscout,
-		"portConfig":  portConfig,
+		"name":          port.Name.Name,
+		"category":      port.Name.Category,
+		"version":       port.DistVersion,
+		"maintainer":    port.Maintainer,
+		"distName":      port.DistName,
+		"extractSuffix": port.ExtractSuffix,
+		"masterSites":   masterSites,
+		"distFiles":     distFiles,
+		"gitHub":        github,
+		"forge":         forge,
+		"goModule":      port.GoModule,
+		"portscout":     port.Portscout,
+		"portConfig":    portConfig,
 	}).OnConflict(goqu.DoUpdate(
 		"category, name",
 		goqu.Record{
-			"name":        port.Name.Name,
-			"category":    port.Name.Category,
-			"version":     port.DistVersion,
-			"maintainer":  port.Maintainer,
-			"masterSites": masterSites,
-			"distFiles":   distFiles,
-			"gitHub":      github,
-			"portscout":   port.Portscout,
-			"portConfig":  portConfig,
+			"name":          port.Name.Name,
+			"category":      port.Name.Category,
+			"version":       port.DistVersion,
+			"maintainer":    port.Maintainer,
+			"distName":      port.DistName,
+			"extractSuffix": port.ExtractSuffix,
+			"masterSites":   masterSites,
+			"distFiles":     distFiles,
+			"gitHub":        github,
+			"forge":         forge,
+			"goModule":      port.GoModule,
+			"portscout":     port.Portscout,
+			"portConfig":    portConfig,
+			// The port has caught up with the version we found
+			"newVersion": goqu.L(`CASE WHEN "ports"."newVersion" = EXCLUDED."version" THEN NULL ELSE "ports"."newVersion" END`),
+			"newFile":    goqu.L(`CASE WHEN "ports"."newVersion" = EXCLUDED."version" THEN NULL ELSE "ports"."newFile" END`),
 		},
 	)).Prepared(true)
 
@@ -156,7 +207,12 @@ func (db *DB) RemovePorts(ports []types.PortName) error {
 }
 
 func (db *DB) GetPorts(limit uint, offset uint) ([]types.PortInfo, error) {
-	query := db.gdb.From("ports").Limit(limit).Offset(offset).Prepared(true)
+	// A stable order, so that pages don't overlap or skip ports
+	query := db.gdb.From("ports").
+		Order(goqu.C("category").Asc(), goqu.C("name").Asc()).
+		Limit(limit).
+		Offset(offset).
+		Prepared(true)
 
 	var rows []portEntry
 
@@ -181,15 +237,19 @@ func (db *DB) GetPorts(limit uint, offset uint) ([]types.PortInfo, error) {
 			github = nil
 		}
 
+		forge, err := forgeFromEntry(row)
+
+		if err != nil {
+			return nil, err
+		}
+
 		masterSites := types.UnmarshalTaggedLists(row.MasterSites)
 		distFiles := types.UnmarshalTaggedLists(row.DistFiles)
 
-		var portConfig types.PortConfig
-
-		err := json.Unmarshal([]byte(row.Config), &portConfig)
+		portConfig, err := portConfigFromEntry(row)
 
 		if err != nil {
-			return nil, fmt.Errorf("Error while unmarshalling PortConfig JSON: %w", err)
+			return nil, err
 		}
 
 		ports = append(ports, types.PortInfo{
@@ -197,12 +257,17 @@ func (db *DB) GetPorts(limit uint, offset uint) ([]types.PortInfo, error) {
 				Category: row.Category,
 				Name:     row.Name,
 			},
-			Portscout:   row.Portscout,
-			Maintainer:  row.Maintainer,
-			MasterSites: masterSites,
-			DistFiles:   distFiles,
-			GitHub:      github,
-			Config:      portConfig,
+			DistName:      row.DistName,
+			DistVersion:   row.Version,
+			ExtractSuffix: row.ExtractSuffix,
+			Portscout:     row.Portscout,
+			Maintainer:    row.Maintainer,
+			MasterSites:   masterSites,
+			DistFiles:     distFiles,
+			GitHub:        github,
+			Forge:         forge,
+			GoModule:      row.GoModule,
+			Config:        portConfig,
 		})
 	}
 
@@ -241,15 +306,19 @@ func (db *DB) GetPortByName(portName types.PortName) (*types.PortInfo, error) {
 		github = nil
 	}
 
+	forge, err := forgeFromEntry(row)
+
+	if err != nil {
+		return nil, err
+	}
+
 	masterSites := types.UnmarshalTaggedLists(row.MasterSites)
 	distFiles := types.UnmarshalTaggedLists(row.DistFiles)
 
-	var portConfig types.PortConfig
-
-	err = json.Unmarshal([]byte(row.Config), &portConfig)
+	portConfig, err := portConfigFromEntry(row)
 
 	if err != nil {
-		return nil, fmt.Errorf("Error while unmarshalling PortConfig JSON: %w", err)
+		return nil, err
 	}
 
 	port = types.PortInfo{
@@ -257,17 +326,98 @@ func (db *DB) GetPortByName(portName types.PortName) (*types.PortInfo, error) {
 			Category: row.Category,
 			Name:     row.Name,
 		},
-		Portscout:   row.Portscout,
-		Maintainer:  row.Maintainer,
-		MasterSites: masterSites,
-		DistFiles:   distFiles,
-		GitHub:      github,
-		Config:      portConfig,
+		DistName:      row.DistName,
+		DistVersion:   row.Version,
+		ExtractSuffix: row.ExtractSuffix,
+		Portscout:     row.Portscout,
+		Maintainer:    row.Maintainer,
+		MasterSites:   masterSites,
+		DistFiles:     distFiles,
+		GitHub:        github,
+		Forge:         forge,
+		GoModule:      row.GoModule,
+		Config:        portConfig,
 	}
 
 	return &port, nil
 }
 
+func forgeFromEntry(row portEntry) (*types.ForgeInfo, error) {
+	if row.Forge == nil {
+		return nil, nil
+	}
+
+	var forge types.ForgeInfo
+
+	err := json.Unmarshal([]byte(*row.Forge), &forge)
+
+	if err != nil {
+		return nil, fmt.Errorf("Error while unmarshalling Forge JSON: %w", err)
+	}
+
+	return &forge, nil
+}
+
+/**
+ * Derives a port's configuration from its stored PORTSCOUT value
+ * rather than the portConfig column: the JSON there loses the limit
+ * regex, and rows written by older versions lack fields such as
+ * LimitWhich, which would then read as 0 rather than -1.
+ */
+func portConfigFromEntry(row portEntry) (types.PortConfig, error) {
+	portConfig, err := types.ParsePortConfig(row.Portscout)
+
+	if err != nil {
+		return portConfig, fmt.Errorf("Error while parsing stored PORTSCOUT value: %w", err)
+	}
+
+	return portConfig, nil
+}
+
+/**
+ * Records a newer version found for a port, along with the URL
+ * of its distfile, and stamps the time of the check.
+ */
+func (db *DB) SetNewVersion(port types.PortName, version string, file string) error {
+	return db.setCrawlOutcome(port, &version, &file)
+}
+
+/**
+ * Records that no newer version exists for a port, clearing any
+ * previously found one which the port has since caught up with,
+ * and stamps the time of the check.
+ */
+func (db *DB) ClearNewVersion(port types.PortName) error {
+	return db.setCrawlOutcome(port, nil, nil)
+}
+
+func (db *DB) setCrawlOutcome(port types.PortName, version *string, file *string) error {
+	query := db.gdb.Update("ports").Set(
+		goqu.Record{
+			"newVersion": version,
+			"newFile":    file,
+			"checkedAt":  goqu.L("CURRENT_TIMESTAMP"),
+		},
+	).Where(goqu.Ex{
+		"name":     port.Name,
+		"category": port.Category,
+	}).Prepared(true)
+
+	sql, args, err := query.ToSQL()
+
+	if err != nil {
+		return err
+	}
+
+	_, err = db.db.Exec(sql, args...)
+
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
 func (db *DB) GetPortUpdates(category *string, maintainer *string) ([]types.PortUpdate, error) {
 	query := db.gdb.From("ports").Prepared(true)
 
@@ -300,6 +450,7 @@ func (db *DB) GetPortUpdates(category *string, maintainer *string) ([]types.Port
 				Name:     row.Name,
 			},
 			Maintainer: row.Maintainer,
+			NewFile:    row.NewFile,
 			Version:    row.Version,
 			NewVersion: row.NewVersion,
 			UpdatedAt:  row.UpdatedAt,
@@ -353,6 +504,288 @@ func (db *DB) GetMaintainerStats() ([]types.MaintainerStats, error) {
 	return nil, nil
 }
 
+func (db *DB) GetHosts() ([]types.HostStatus, error) {
+	query := db.gdb.From("hosts").Order(goqu.C("hostname").Asc()).Prepared(true)
+
+	var rows []hostEntry
+
+	hosts := make([]types.HostStatus, 0)
+
+	err := query.ScanStructs(&rows)
+
+	if err != nil {
+		return nil, fmt.Errorf("Error while scanning structs: %w", err)
+	}
+
+	for _, row := range rows {
+		hosts = append(hosts, hostFromEntry(row))
+	}
+
+	return hosts, nil
+}
+
+func (db *DB) GetHost(hostname string) (*types.HostStatus, error) {
+	query := db.gdb.From("hosts").Where(
+		goqu.C("hostname").Eq(hostname),
+	).Prepared(true)
+
+	var row hostEntry
+
+	found, err := query.ScanStruct(&row)
+
+	if err != nil {
+		return nil, fmt.Errorf("Error while scanning struct: %w", err)
+	}
+
+	if !found {
+		return nil, nil
+	}
+
+	host := hostFromEntry(row)
+
+	return &host, nil
+}
+
+func (db *DB) GetDownHosts() ([]types.HostStatus, error) {
+	query := db.gdb.From("hosts").Where(
+		goqu.C("isDown").IsTrue(),
+	).Order(goqu.C("hostname").Asc()).Prepared(true)
+
+	var rows []hostEntry
+
+	hosts := make([]types.HostStatus, 0)
+
+	err := query.ScanStructs(&rows)
+
+	if err != nil {
+		return nil, fmt.Errorf("Error while scanning structs: %w", err)
+	}
+
+	for _, row := range rows {
+		hosts = append(hosts, hostFromEntry(row))
+	}
+
+	return hosts, nil
+}
+
+func (db *DB) UpdateHost(host types.HostStatus) error {
+	var lastError *string
+
+	if host.LastError != "" {
+		lastError = &host.LastError
+	}
+
+	record := goqu.Record{
+		"hostname":            host.Hostname,
+		"accessedAt":          host.AccessedAt,
+		"isDown":              host.IsDown,
+		"downUntil":           host.DownUntil,
+		"successCount":        host.SuccessCount,
+		"failureCount":        host.FailureCount,
+		"consecutiveFailures": host.ConsecutiveFailures,
+		"lastError":           lastError,
+	}
+
+	query := db.gdb.Insert("hosts").Rows(record).OnConflict(
+		goqu.DoUpdate("hostname", record),
+	).Prepared(true)
+
+	sql, args, err := query.ToSQL()
+
+	if err != nil {
+		return err
+	}
+
+	_, err = db.db.Exec(sql, args...)
+
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+/**
+ * Returns the rate limiter state of each host. The last access is
+ * the later of the last request made and the last successful crawl,
+ * since hosts recorded before the limiter kept state only have the
+ * latter.
+ */
+func (db *DB) GetHostRates() ([]types.HostRateState, error) {
+	query := db.gdb.From("hosts").Prepared(true)
+
+	var rows []hostRateEntry
+
+	states := make([]types.HostRateState, 0)
+
+	err := query.ScanStructs(&rows)
+
+	if err != nil {
+		return nil, fmt.Errorf("Error while scanning structs: %w", err)
+	}
+
+	for _, row := range rows {
+		state := types.HostRateState{
+			Hostname:   row.Hostname,
+			LastAccess: row.LastRequestAt,
+		}
+
+		if row.AccessedAt != nil && (state.LastAccess == nil || row.AccessedAt.After(*state.LastAccess)) {
+			state.LastAccess = row.AccessedAt
+		}
+
+		if row.LearnedRate != nil {
+			state.LearnedRate = *row.LearnedRate
+		}
+
+		states = append(states, state)
+	}
+
+	return states, nil
+}
+
+/**
+ * Saves rate limiter state, leaving the rest of each host's
+ * status alone.
+ */
+func (db *DB) UpdateHostRates(states []types.HostRateState) error {
+	if len(states) == 0 {
+		return nil
+	}
+
+	tx, err := db.db.Begin()
+
+	if err != nil {
+		return fmt.Errorf("Error starting transaction: %w", err)
+	}
+
+	defer tx.Rollback()
+
+	for _, state := range states {
+		var learnedRate *float64
+
+		if state.LearnedRate > 0 {
+			learnedRate = &state.LearnedRate
+		}
+
+		// accessedAt would otherwise default to now, although
+		// the host may never have been crawled successfully
+		record := goqu.Record{
+			"hostname":      state.Hostname,
+			"accessedAt":    nil,
+			"lastRequestAt": state.LastAccess,
+			"learnedRate":   learnedRate,
+		}
+
+		query := db.gdb.Insert("hosts").Rows(record).OnConflict(
+			goqu.DoUpdate("hostname", goqu.Record{
+				"lastRequestAt": state.LastAccess,
+				"learnedRate":   learnedRate,
+			}),
+		).Prepared(true)
+
+		sql, args, err := query.ToSQL()
+
+		if err != nil {
+			return err
+		}
+
+		_, err = tx.Exec(sql, args...)
+
+		if err != nil {
+			return fmt.Errorf("Error updating host rate: %w", err)
+		}
+	}
+
+	return tx.Commit()
+}
+
+func hostFromEntry(row hostEntry) types.HostStatus {
+	host := types.HostStatus{
+		Hostname:            row.Hostname,
+		AccessedAt:          row.AccessedAt,
+		IsDown:              row.IsDown,
+		DownUntil:           row.DownUntil,
+		SuccessCount:        row.SuccessCount,
+		FailureCount:        row.FailureCount,
+		ConsecutiveFailures: row.ConsecutiveFailures,
+	}
+
+	if row.LastError != nil {
+		host.LastError = *row.LastError
+	}
+
+	return host
+}
+
+// Returns the validators of a listing; the file lists aren't stored.
+func (db *DB) GetListingValidators(url string) (*types.Listing, error) {
+	query := db.gdb.From("listings").Where(
+		goqu.C("url").Eq(url),
+	).Prepared(true)
+
+	var row listingEntry
+
+	found, err := query.ScanStruct(&row)
+
+	if err != nil {
+		return nil, fmt.Errorf("Error while scanning struct: %w", err)
+	}
+
+	if !found {
+		return nil, nil
+	}
+
+	listing := types.Listing{
+		Url: row.Url,
+	}
+
+	if row.ETag != nil {
+		listing.ETag = *row.ETag
+	}
+
+	if row.LastModified != nil {
+		listing.LastModified = *row.LastModified
+	}
+
+	return &listing, nil
+}
+
+func (db *DB) SetListingValidators(listing types.Listing) error {
+	record := goqu.Record{
+		"url":          listing.Url,
+		"etag":         nullString(listing.ETag),
+		"lastModified": nullString(listing.LastModified),
+		"fetchedAt":    goqu.L("CURRENT_TIMESTAMP"),
+	}
+
+	query := db.gdb.Insert("listings").Rows(record).OnConflict(
+		goqu.DoUpdate("url", record),
+	).Prepared(true)
+
+	sql, args, err := query.ToSQL()
+
+	if err != nil {
+		return err
+	}
+
+	_, err = db.db.Exec(sql, args...)
+
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+func nullString(s string) *string {
+	if s == "" {
+		return nil
+	}
+
+	return &s
+}
+
 func (db *DB) GetLastCommit() (string, error) {
 	query := db.gdb.From("repo").Select("lastCommit").Limit(1).Prepared(true)
 
@@ -5,6 +5,7 @@ import (
 	"log/slog"
 	"os"
 	"testing"
+	"time"
 
 	"github.com/samott/portscout2/config"
 	"github.com/samott/portscout2/types"
@@ -188,3 +189,238 @@ func TestGetPortUpdates(t *testing.T) {
 		t.Fatal("Incorrect number of updates found for maintainer")
 	}
 }
+
+func TestSetNewVersion(t *testing.T) {
+	name := types.PortName{
+		Name:     "test-nv",
+		Category: "cat",
+	}
+
+	err := db.UpdatePort(types.PortInfo{
+		Name:        name,
+		DistVersion: "1.0",
+		Maintainer:  "test@example.net",
+	})
+
+	if err != nil {
+		t.Fatal("UpdatePort failed")
+	}
+
+	err = db.SetNewVersion(name, "1.1", "http://www.example.net/test-1.1.tar.gz")
+
+	if err != nil {
+		t.Fatal("SetNewVersion failed")
+	}
+
+	cat := "cat"
+
+	updates, err := db.GetPortUpdates(&cat, nil)
+
+	if err != nil {
+		t.Fatal("GetPortUpdates failed")
+	}
+
+	var update *types.PortUpdate
+
+	for i := range updates {
+		if updates[i].Name == name {
+			update = &updates[i]
+		}
+	}
+
+	if update == nil {
+		t.Fatal("Port not found")
+	}
+
+	if update.NewVersion == nil || *update.NewVersion != "1.1" {
+		t.Fatal("Incorrect newVersion value")
+	}
+
+	if update.NewFile == nil || *update.NewFile != "http://www.example.net/test-1.1.tar.gz" {
+		t.Fatal("Incorrect newFile value")
+	}
+
+	if update.CheckedAt == nil {
+		t.Fatal("checkedAt not set")
+	}
+
+	// Port catches up with the new version
+	err = db.UpdatePort(types.PortInfo{
+		Name:        name,
+		DistVersion: "1.1",
+		Maintainer:  "test@example.net",
+	})
+
+	if err != nil {
+		t.Fatal("UpdatePort failed")
+	}
+
+	updates, err = db.GetPortUpdates(&cat, nil)
+
+	if err != nil {
+		t.Fatal("GetPortUpdates failed")
+	}
+
+	for _, update := range updates {
+		if update.Name == name && (update.NewVersion != nil || update.NewFile != nil) {
+			t.Fatal("Stale newVersion not cleared")
+		}
+	}
+
+	err = db.SetNewVersion(name, "1.2", "http://www.example.net/test-1.2.tar.gz")
+
+	if err != nil {
+		t.Fatal("SetNewVersion failed")
+	}
+
+	err = db.ClearNewVersion(name)
+
+	if err != nil {
+		t.Fatal("ClearNewVersion failed")
+	}
+
+	updates, err = db.GetPortUpdates(&cat, nil)
+
+	if err != nil {
+		t.Fatal("GetPortUpdates failed")
+	}
+
+	for _, update := range updates {
+		if update.Name == name && (update.NewVersion != nil || update.NewFile != nil) {
+			t.Fatal("newVersion not cleared")
+		}
+	}
+
+	err = db.RemovePort(name)
+
+	if err != nil {
+		t.Fatal("RemovePort failed")
+	}
+}
+
+func TestUpdateHost(t *testing.T) {
+	downUntil := time.Now().Add(time.Hour).Truncate(time.Second)
+
+	err := db.UpdateHost(types.HostStatus{
+		Hostname:            "down.example.net",
+		IsDown:              true,
+		DownUntil:           &downUntil,
+		FailureCount:        3,
+		ConsecutiveFailures: 3,
+		LastError:           "Connection refused",
+	})
+
+	if err != nil {
+		t.Fatal("UpdateHost failed:", err)
+	}
+
+	host, err := db.GetHost("down.example.net")
+
+	if err != nil || host == nil {
+		t.Fatal("GetHost failed:", err)
+	}
+
+	if !host.IsDown || host.ConsecutiveFailures != 3 || host.LastError != "Connection refused" {
+		t.Fatal("Incorrect host status:", host)
+	}
+
+	hosts, err := db.GetDownHosts()
+
+	if err != nil {
+		t.Fatal("GetDownHosts failed:", err)
+	}
+
+	found := false
+
+	for _, h := range hosts {
+		if h.Hostname == "down.example.net" {
+			found = true
+		}
+	}
+
+	if !found {
+		t.Fatal("Down host not listed")
+	}
+
+	err = db.UpdateHost(types.HostStatus{
+		Hostname:     "down.example.net",
+		SuccessCount: 1,
+		FailureCount: 3,
+	})
+
+	if err != nil {
+		t.Fatal("UpdateHost failed:", err)
+	}
+
+	host, _ = db.GetHost("down.example.net")
+
+	if host.IsDown || host.DownUntil != nil || host.LastError != "" {
+		t.Fatal("Host status not updated:", host)
+	}
+}
+
+func TestUpdateHostRates(t *testing.T) {
+	accessed := time.Now().Truncate(time.Second)
+
+	err := db.UpdateHostRates([]types.HostRateState{
+		{Hostname: "rate.example.net", LastAccess: &accessed, LearnedRate: 0.5},
+	})
+
+	if err != nil {
+		t.Fatal("UpdateHostRates failed:", err)
+	}
+
+	states, err := db.GetHostRates()
+
+	if err != nil {
+		t.Fatal("GetHostRates failed:", err)
+	}
+
+	for _, state := range states {
+		if state.Hostname != "rate.example.net" {
+			continue
+		}
+
+		if state.LearnedRate != 0.5 || state.LastAccess == nil || !state.LastAccess.Equal(accessed) {
+			t.Fatal("Incorrect host rate:", state)
+		}
+
+		host, _ := db.GetHost("rate.example.net")
+
+		if host == nil || host.AccessedAt != nil {
+			t.Fatal("Host status affected by rate update:", host)
+		}
+
+		return
+	}
+
+	t.Fatal("Host rate not saved")
+}
+
+func TestSetListingValidators(t *testing.T) {
+	err := db.SetListingValidators(types.Listing{
+		Url:          "https://www.example.net/pub/",
+		ETag:         `"abc"`,
+		LastModified: "Wed, 01 Jan 2025 12:00:00 GMT",
+	})
+
+	if err != nil {
+		t.Fatal("SetListingValidators failed:", err)
+	}
+
+	listing, err := db.GetListingValidators("https://www.example.net/pub/")
+
+	if err != nil || listing == nil {
+		t.Fatal("GetListingValidators failed:", err)
+	}
+
+	if listing.ETag != `"abc"` || listing.LastModified != "Wed, 01 Jan 2025 12:00:00 GMT" {
+		t.Fatal("Incorrect validators:", listing)
+	}
+
+	listing, err = db.GetListingValidators("https://www.example.net/other/")
+
+	if err != nil || listing != nil {
+		t.Fatal("Unexpected validators:", listing, err)
+	}
+}
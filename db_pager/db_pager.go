@@ -27,7 +27,9 @@ func (p *Pager[T]) Out() <-chan T {
 }
 
 func (p *Pager[T]) Run(ctx context.Context) {
-	for {
+	defer close(p.out)
+
+	for ctx.Err() == nil {
 		results, err := p.fetch(p.limit, p.offset)
 
 		if err != nil || len(results) == 0 {
@@ -37,9 +39,11 @@ func (p *Pager[T]) Run(ctx context.Context) {
 		p.offset += p.limit
 
 		for _, result := range results {
-			p.out <- result
+			select {
+			case p.out <- result:
+			case <-ctx.Done():
+				return
+			}
 		}
 	}
-
-	close(p.out)
 }
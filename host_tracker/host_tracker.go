@@ -0,0 +1,178 @@
+package host_tracker
+
+import (
+	"log/slog"
+	"sync"
+	"time"
+
+	"github.com/samott/portscout2/types"
+)
+
+type HostStore interface {
+	GetHosts() ([]types.HostStatus, error)
+	UpdateHost(host types.HostStatus) error
+}
+
+type HostTracker struct {
+	mu          sync.Mutex
+	store       HostStore
+	hosts       map[string]*types.HostStatus
+	maxFailures uint
+	minDowntime time.Duration
+	maxDowntime time.Duration
+}
+
+/**
+ * Creates a tracker which keeps the status of each host we crawl,
+ * loading what's known from previous runs from the store and
+ * writing changes back as they happen.
+ *
+ * A host is marked down after maxFailures consecutive failures.
+ * It is left alone for minDowntime before being tried again, and
+ * each further failure doubles that, up to maxDowntime.
+ */
+func NewHostTracker(store HostStore, maxFailures uint, minDowntime time.Duration, maxDowntime time.Duration) (*HostTracker, error) {
+	hosts, err := store.GetHosts()
+
+	if err != nil {
+		return nil, err
+	}
+
+	if maxFailures == 0 {
+		maxFailures = 1
+	}
+
+	if maxDowntime < minDowntime {
+		maxDowntime = minDowntime
+	}
+
+	t := &HostTracker{
+		store:       store,
+		hosts:       make(map[string]*types.HostStatus),
+		maxFailures: maxFailures,
+		minDowntime: minDowntime,
+		maxDowntime: maxDowntime,
+	}
+
+	for _, host := range hosts {
+		t.hosts[host.Hostname] = &host
+	}
+
+	return t, nil
+}
+
+// Returns the last known status of a host, if there is one.
+func (t *HostTracker) Status(hostname string) (types.HostStatus, bool) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	host, ok := t.hosts[hostname]
+
+	if !ok {
+		return types.HostStatus{}, false
+	}
+
+	return *host, true
+}
+
+// Records a successful crawl of a host.
+func (t *HostTracker) RecordSuccess(hostname string) {
+	t.mu.Lock()
+
+	host := t.get(hostname)
+	now := time.Now()
+
+	host.AccessedAt = &now
+	host.IsDown = false
+	host.DownUntil = nil
+	host.SuccessCount++
+	host.ConsecutiveFailures = 0
+	host.LastError = ""
+
+	status := *host
+
+	t.mu.Unlock()
+
+	t.save(status)
+}
+
+/**
+ * Records a failed crawl of a host, marking it down if it has
+ * failed too many times in a row. A failed re-probe of a host
+ * which is already down pushes its next attempt further back.
+ */
+func (t *HostTracker) RecordFailure(hostname string, err error) {
+	t.mu.Lock()
+
+	host := t.get(hostname)
+
+	host.FailureCount++
+	host.ConsecutiveFailures++
+	host.LastError = err.Error()
+
+	if host.ConsecutiveFailures >= t.maxFailures {
+		downUntil := time.Now().Add(t.downtime(host.ConsecutiveFailures - t.maxFailures))
+
+		if !host.IsDown {
+			slog.Warn("Marking host as down", "host", hostname, "until", downUntil, "err", err)
+		}
+
+		host.IsDown = true
+		host.DownUntil = &downUntil
+	}
+
+	status := *host
+
+	t.mu.Unlock()
+
+	t.save(status)
+}
+
+/**
+ * Reports whether a host should be crawled: either it isn't down,
+ * or it has been down long enough to be worth trying again.
+ */
+func (t *HostTracker) IsAvailable(hostname string) bool {
+	status, ok := t.Status(hostname)
+
+	return !ok || isAvailable(status, time.Now())
+}
+
+func isAvailable(status types.HostStatus, now time.Time) bool {
+	if !status.IsDown {
+		return true
+	}
+
+	return status.DownUntil == nil || !now.Before(*status.DownUntil)
+}
+
+// Returns the downtime after the given number of failed re-probes.
+func (t *HostTracker) downtime(reprobes uint) time.Duration {
+	downtime := t.minDowntime
+
+	for i := uint(0); i < reprobes && downtime < t.maxDowntime; i++ {
+		downtime *= 2
+	}
+
+	return min(downtime, t.maxDowntime)
+}
+
+// Must be called with the mutex held.
+func (t *HostTracker) get(hostname string) *types.HostStatus {
+	host, ok := t.hosts[hostname]
+
+	if !ok {
+		host = &types.HostStatus{
+			Hostname: hostname,
+		}
+		t.hosts[hostname] = host
+	}
+
+	return host
+}
+
+func (t *HostTracker) save(host types.HostStatus) {
+	if err := t.store.UpdateHost(host); err != nil {
+		slog.Error("Error updating host status", "host", host.Hostname, "err", err)
+	}
+}
@@ -0,0 +1,115 @@
+package host_tracker
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/samott/portscout2/types"
+)
+
+type fakeStore struct {
+	hosts   []types.HostStatus
+	updated []types.HostStatus
+}
+
+func (s *fakeStore) GetHosts() ([]types.HostStatus, error) {
+	return s.hosts, nil
+}
+
+func (s *fakeStore) UpdateHost(host types.HostStatus) error {
+	s.updated = append(s.updated, host)
+	return nil
+}
+
+func TestHostTracker(t *testing.T) {
+	store := &fakeStore{
+		hosts: []types.HostStatus{
+			{Hostname: "down.example.net", IsDown: true},
+		},
+	}
+
+	tracker, err := NewHostTracker(store, 2, time.Minute, 3*time.Minute)
+
+	if err != nil {
+		t.Fatal("NewHostTracker failed:", err)
+	}
+
+	if _, ok := tracker.Status("unknown.example.net"); ok {
+		t.Fatal("Unexpected status for unknown host")
+	}
+
+	status, ok := tracker.Status("down.example.net")
+
+	if !ok || !status.IsDown {
+		t.Fatal("Incorrect loaded status:", status)
+	}
+
+	tracker.RecordSuccess("down.example.net")
+
+	status, _ = tracker.Status("down.example.net")
+
+	if status.IsDown || status.AccessedAt == nil {
+		t.Fatal("Success not recorded:", status)
+	}
+
+	if len(store.updated) != 1 || store.updated[0].Hostname != "down.example.net" {
+		t.Fatal("Status not persisted:", store.updated)
+	}
+}
+
+func TestHostTrackerFailures(t *testing.T) {
+	tracker, err := NewHostTracker(&fakeStore{}, 2, time.Minute, 3*time.Minute)
+
+	if err != nil {
+		t.Fatal("NewHostTracker failed:", err)
+	}
+
+	fail := errors.New("Connection refused")
+
+	tracker.RecordFailure("flaky.example.net", fail)
+
+	if !tracker.IsAvailable("flaky.example.net") {
+		t.Fatal("Host marked down too soon")
+	}
+
+	tracker.RecordFailure("flaky.example.net", fail)
+
+	status, _ := tracker.Status("flaky.example.net")
+
+	if !status.IsDown || tracker.IsAvailable("flaky.example.net") {
+		t.Fatal("Host not marked down:", status)
+	}
+
+	if status.LastError != "Connection refused" || status.FailureCount != 2 {
+		t.Fatal("Incorrect failure details:", status)
+	}
+
+	if !isAvailable(status, status.DownUntil.Add(time.Second)) {
+		t.Fatal("Host not due for re-probe after downtime")
+	}
+
+	cases := []struct {
+		reprobes uint
+		expected time.Duration
+	}{
+		{0, time.Minute},
+		{1, 2 * time.Minute},
+		{2, 3 * time.Minute},
+		{10, 3 * time.Minute},
+	}
+
+	for _, tc := range cases {
+		if d := tracker.downtime(tc.reprobes); d != tc.expected {
+			t.Fatal("Incorrect downtime after", tc.reprobes, "re-probes:", d)
+		}
+	}
+
+	tracker.RecordSuccess("flaky.example.net")
+
+	status, _ = tracker.Status("flaky.example.net")
+
+	if status.IsDown || status.ConsecutiveFailures != 0 || status.LastError != "" {
+		t.Fatal("Host not restored:", status)
+	}
+}
@@ -0,0 +1,133 @@
+package listing_cache
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"net/url"
+	"os"
+	"path/filepath"
+
+	"github.com/samott/portscout2/types"
+)
+
+type ValidatorStore interface {
+	GetListingValidators(url string) (*types.Listing, error)
+	SetListingValidators(listing types.Listing) error
+}
+
+/**
+ * Keeps the parsed file lists of directory listings on disk, and
+ * their ETag/Last-Modified validators in the store, so that pages
+ * which haven't changed since the last run needn't be downloaded
+ * or parsed again.
+ */
+type ListingCache struct {
+	dir   string
+	store ValidatorStore
+}
+
+func NewListingCache(dir string, store ValidatorStore) (*ListingCache, error) {
+	err := os.MkdirAll(dir, 0o755)
+
+	if err != nil {
+		return nil, fmt.Errorf("Error creating cache directory: %w", err)
+	}
+
+	return &ListingCache{
+		dir:   dir,
+		store: store,
+	}, nil
+}
+
+/**
+ * Returns the cached listing for a URL, or nil if there isn't one.
+ * Both halves must be present and agree with one another, since a
+ * conditional request made with validators for a different copy of
+ * the page would lead us to reuse the wrong file list.
+ */
+func (c *ListingCache) Get(site *url.URL) (*types.Listing, error) {
+	validators, err := c.store.GetListingValidators(site.String())
+
+	if err != nil || validators == nil {
+		return nil, err
+	}
+
+	data, err := os.ReadFile(c.path(site))
+
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, nil
+	}
+
+	if err != nil {
+		return nil, fmt.Errorf("Error reading cached listing: %w", err)
+	}
+
+	var listing types.Listing
+
+	err = json.Unmarshal(data, &listing)
+
+	if err != nil {
+		return nil, fmt.Errorf("Error decoding cached listing: %w", err)
+	}
+
+	if listing.Url != validators.Url || listing.ETag != validators.ETag || listing.LastModified != validators.LastModified {
+		return nil, nil
+	}
+
+	return &listing, nil
+}
+
+/**
+ * Stores a listing. The file is written first (via a temporary
+ * file, so a crash can't leave it truncated), so that the validators
+ * never refer to a file list we don't have.
+ */
+func (c *ListingCache) Put(listing types.Listing) error {
+	site, err := url.Parse(listing.Url)
+
+	if err != nil {
+		return fmt.Errorf("Invalid listing URL: %w", err)
+	}
+
+	data, err := json.Marshal(listing)
+
+	if err != nil {
+		return fmt.Errorf("Error encoding listing: %w", err)
+	}
+
+	tmp, err := os.CreateTemp(c.dir, "listing-*")
+
+	if err != nil {
+		return fmt.Errorf("Error creating cache file: %w", err)
+	}
+
+	defer os.Remove(tmp.Name())
+
+	_, err = tmp.Write(data)
+
+	if closeErr := tmp.Close(); err == nil {
+		err = closeErr
+	}
+
+	if err != nil {
+		return fmt.Errorf("Error writing cache file: %w", err)
+	}
+
+	err = os.Rename(tmp.Name(), c.path(site))
+
+	if err != nil {
+		return fmt.Errorf("Error writing cache file: %w", err)
+	}
+
+	return c.store.SetListingValidators(listing)
+}
+
+func (c *ListingCache) path(site *url.URL) string {
+	sum := sha256.Sum256([]byte(site.String()))
+
+	return filepath.Join(c.dir, hex.EncodeToString(sum[:])+".json")
+}
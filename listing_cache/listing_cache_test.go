@@ -0,0 +1,83 @@
+package listing_cache
+
+import (
+	"net/url"
+	"os"
+	"testing"
+
+	"github.com/samott/portscout2/types"
+)
+
+type fakeStore map[string]types.Listing
+
+func (s fakeStore) GetListingValidators(url string) (*types.Listing, error) {
+	listing, ok := s[url]
+
+	if !ok {
+		return nil, nil
+	}
+
+	return &listing, nil
+}
+
+func (s fakeStore) SetListingValidators(listing types.Listing) error {
+	s[listing.Url] = types.Listing{
+		Url:          listing.Url,
+		ETag:         listing.ETag,
+		LastModified: listing.LastModified,
+	}
+
+	return nil
+}
+
+func TestListingCache(t *testing.T) {
+	store := fakeStore{}
+
+	cache, err := NewListingCache(t.TempDir(), store)
+
+	if err != nil {
+		t.Fatal("NewListingCache failed:", err)
+	}
+
+	site, _ := url.Parse("https://www.example.net/pub/foo/")
+
+	if listing, err := cache.Get(site); listing != nil || err != nil {
+		t.Fatal("Unexpected cached listing:", listing, err)
+	}
+
+	err = cache.Put(types.Listing{
+		Url:   site.String(),
+		ETag:  `"abc"`,
+		Files: []string{"https://www.example.net/pub/foo/foo-1.0.tar.gz"},
+		Dirs:  []string{"https://www.example.net/pub/foo/old/"},
+	})
+
+	if err != nil {
+		t.Fatal("Put failed:", err)
+	}
+
+	listing, err := cache.Get(site)
+
+	if err != nil || listing == nil {
+		t.Fatal("Get failed:", err)
+	}
+
+	if listing.ETag != `"abc"` || len(listing.Files) != 1 || len(listing.Dirs) != 1 {
+		t.Fatal("Incorrect cached listing:", listing)
+	}
+
+	// Validators for a different copy of the page
+	store[site.String()] = types.Listing{Url: site.String(), ETag: `"def"`}
+
+	if listing, _ := cache.Get(site); listing != nil {
+		t.Fatal("Mismatched listing returned")
+	}
+
+	os.RemoveAll(cache.dir)
+
+	store[site.String()] = types.Listing{Url: site.String(), ETag: `"abc"`}
+
+	if listing, err := cache.Get(site); listing != nil || err != nil {
+		t.Fatal("Listing returned without cache file:", listing, err)
+	}
+}
@@ -3,22 +3,26 @@ package main
 import (
 	"flag"
 	"os"
+	"os/signal"
+	"syscall"
 
 	"context"
-	"crypto/rand"
 	"log/slog"
-	"math/big"
-	"net/url"
+	"sync"
 	"time"
 
 	"github.com/samott/portscout2/config"
 	"github.com/samott/portscout2/crawl_limiter"
+	"github.com/samott/portscout2/crawl_planner"
 	"github.com/samott/portscout2/crawler"
 	"github.com/samott/portscout2/db"
 	"github.com/samott/portscout2/db_pager"
+	"github.com/samott/portscout2/host_tracker"
+	"github.com/samott/portscout2/listing_cache"
 	"github.com/samott/portscout2/repo"
 	"github.com/samott/portscout2/tree"
 	"github.com/samott/portscout2/types"
+	"github.com/samott/portscout2/version"
 )
 
 func main() {
@@ -66,7 +70,18 @@ func main() {
 	}
 
 	tr := tree.NewTree(cfg.Tree.MakeCmd, cfg.Tree.PortsDir, cfg.Tree.MakeThreads)
-	ctx, cancel := context.WithCancel(context.Background())
+
+	giteaHosts := []string{"codeberg.org"}
+
+	for _, forge := range cfg.Crawler.Forges {
+		if forge.Type == types.ForgeGitea {
+			giteaHosts = append(giteaHosts, forge.Host)
+		}
+	}
+
+	tr.SetGiteaHosts(giteaHosts)
+	ctx, cancel := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer cancel()
 
 	if len(ports) > 0 {
 		go tr.QueryPorts(ctx)
@@ -115,42 +130,309 @@ func main() {
 
 	// Stage 2: find updates
 
+	hosts, err := host_tracker.NewHostTracker(
+		db,
+		cfg.HostTracker.MaxFailures,
+		time.Duration(cfg.HostTracker.MinDowntimeMs)*time.Millisecond,
+		time.Duration(cfg.HostTracker.MaxDowntimeMs)*time.Millisecond,
+	)
+
+	if err != nil {
+		slog.Error("Failed to load host status", "err", err)
+		os.Exit(1)
+	}
+
 	crawl_lim := crawl_limiter.NewCrawlLimiter(cfg.CrawlLimiter.MaxReqsCount, time.Duration(cfg.CrawlLimiter.MaxReqsWindowMs)*time.Millisecond)
+	crawl_lim.SetMaxInFlight(cfg.CrawlLimiter.MaxInFlight)
+	crawl_lim.SetAdaptive(cfg.CrawlLimiter.Adaptive.Enabled, time.Duration(cfg.CrawlLimiter.Adaptive.SlowResponseMs)*time.Millisecond)
+
+	for _, host := range cfg.CrawlLimiter.Hosts {
+		crawl_lim.SetHostRate(host.Pattern, host.MaxReqsCount, time.Duration(host.MaxReqsWindowMs)*time.Millisecond)
+	}
+
+	err = crawl_lim.SetStore(db)
+
+	if err != nil {
+		slog.Error("Failed to load crawl limiter state", "err", err)
+		os.Exit(1)
+	}
+
+	if cfg.CrawlLimiter.FlushIntervalMs > 0 {
+		go crawl_lim.RunFlush(ctx, time.Duration(cfg.CrawlLimiter.FlushIntervalMs)*time.Millisecond)
+	}
+
 	crawl := crawler.NewCrawler(cfg.Crawler.QueueSize)
 	crawl.SetLimiter(crawl_lim)
+	crawl.SetGuess(cfg.Crawler.Guess)
+	crawl.SetTimeouts(
+		time.Duration(cfg.Crawler.RequestTimeoutMs)*time.Millisecond,
+		time.Duration(cfg.Crawler.SiteTimeoutMs)*time.Millisecond,
+	)
+	crawl.SetHostTracker(hosts)
+	crawl.SetRobots(
+		cfg.Crawler.Robots.Enabled,
+		time.Duration(cfg.Crawler.Robots.ExpiryMs)*time.Millisecond,
+		cfg.Crawler.Robots.IgnoreHosts,
+	)
+
+	ftpTls, err := crawler.ParseFtpTlsMode(cfg.Crawler.Ftp.Tls)
+
+	if err != nil {
+		slog.Error("Failed to set up FTP", "err", err)
+		os.Exit(1)
+	}
+
+	crawl.SetFtpOptions(crawler.FtpOptions{
+		Password:    cfg.Crawler.Ftp.Password,
+		Tls:         ftpTls,
+		IdleTimeout: time.Duration(cfg.Crawler.Ftp.IdleTimeoutMs) * time.Millisecond,
+	})
+
+	if cfg.Crawler.CacheDir != "" {
+		listings, err := listing_cache.NewListingCache(cfg.Crawler.CacheDir, db)
+
+		if err != nil {
+			slog.Error("Failed to set up listing cache", "err", err)
+			os.Exit(1)
+		}
+
+		crawl.SetListingCache(listings)
+	}
+
+	retryPolicies := map[crawler.ErrorClass]config.RetryPolicy{
+		crawler.ErrorTransient: cfg.Crawler.Retry.Transient,
+		crawler.ErrorThrottled: cfg.Crawler.Retry.Throttled,
+	}
+
+	for class, policy := range retryPolicies {
+		// Keep the crawler's defaults for anything not configured
+		if policy.MaxAttempts > 0 {
+			crawl.SetRetryPolicy(class, retryPolicy(policy))
+		}
+	}
+
+	gitHub, err := crawler.NewGitHubHandler(crawl, cfg.Crawler.GitHub.ApiUrl, cfg.Crawler.GitHub.Token)
+
+	if err != nil {
+		slog.Error("Failed to set up GitHub handler", "err", err)
+		os.Exit(1)
+	}
+
+	crawl.RegisterHandler("https", "github.com", gitHub)
+
+	forges := crawler.NewForgeHandler(crawl)
+
+	for _, forge := range cfg.Crawler.Forges {
+		err := forges.AddInstance(forge.Type, forge.Host, forge.ApiUrl, forge.Token)
+
+		if err != nil {
+			slog.Error("Failed to set up forge handler", "host", forge.Host, "err", err)
+			os.Exit(1)
+		}
+	}
+
+	// Forge instances may be called anything, so the forge handler
+	// takes over from the generic one, which it falls back on
+	crawl.RegisterHandler("https", "", forges)
+
+	pyPI, err := crawler.NewPyPIHandler(crawl, cfg.Crawler.PyPI.IndexUrl)
+
+	if err != nil {
+		slog.Error("Failed to set up PyPI handler", "err", err)
+		os.Exit(1)
+	}
+
+	crawl.RegisterHandler("https", "files.pythonhosted.org", pyPI)
+
+	cpan, err := crawler.NewCpanHandler(crawl, cfg.Crawler.Cpan.ApiUrl)
+
+	if err != nil {
+		slog.Error("Failed to set up CPAN handler", "err", err)
+		os.Exit(1)
+	}
+
+	crawl.RegisterHandler("https", "metacpan.org", cpan)
+
+	crates, err := crawler.NewCratesHandler(crawl, cfg.Crawler.Crates.IndexUrl)
+
+	if err != nil {
+		slog.Error("Failed to set up crates handler", "err", err)
+		os.Exit(1)
+	}
+
+	crawl.RegisterHandler("https", "crates.io", crates)
+
+	goProxy, err := crawler.NewGoProxyHandler(crawl, cfg.Crawler.GoProxy.Url)
+
+	if err != nil {
+		slog.Error("Failed to set up Go proxy handler", "err", err)
+		os.Exit(1)
+	}
+
+	crawl.RegisterHandler("https", "pkg.go.dev", goProxy)
+
+	npm, err := crawler.NewNpmHandler(crawl, cfg.Crawler.Npm.RegistryUrl)
+
+	if err != nil {
+		slog.Error("Failed to set up npm handler", "err", err)
+		os.Exit(1)
+	}
+
+	crawl.RegisterHandler("https", "www.npmjs.com", npm)
+
+	rubyGems, err := crawler.NewRubyGemsHandler(crawl, cfg.Crawler.RubyGems.Url)
+
+	if err != nil {
+		slog.Error("Failed to set up RubyGems handler", "err", err)
+		os.Exit(1)
+	}
+
+	crawl.RegisterHandler("https", "rubygems.org", rubyGems)
+
+	hackage, err := crawler.NewHackageHandler(crawl, cfg.Crawler.Hackage.Url)
+
+	if err != nil {
+		slog.Error("Failed to set up Hackage handler", "err", err)
+		os.Exit(1)
+	}
+
+	crawl.RegisterHandler("https", "hackage.haskell.org", hackage)
+
+	go crawl.Run(ctx)
+
+	planner := crawl_planner.NewPlanner(hosts)
 
 	pager := db_pager.NewPager[types.PortInfo](db.GetPorts, cfg.Db.PageSize)
 
 	go pager.Run(ctx)
 
-	for port := range pager.Out() {
-		for group := range port.DistFiles {
-			if _, ok := port.MasterSites[group]; !ok {
-				// No sites for this distfile
+	// Ports may be crawled at several sites (one per distfile
+	// group, plus any index site, each with its own mirrors),
+	// each as a separate job; results are collected here until
+	// all of a port's jobs have completed.
+	var pendingMu sync.Mutex
+	pending := make(map[types.PortName]*pendingPort)
+
+	go func() {
+		for port := range pager.Out() {
+			jobs := planner.Plan(port)
+
+			if len(jobs) == 0 {
 				continue
 			}
 
-			randInt, _ := rand.Int(rand.Reader, big.NewInt(int64(len(port.MasterSites[group].Items))))
-			idx := randInt.Int64()
-			site, err := url.Parse(port.MasterSites[group].Items[idx])
+			pendingMu.Lock()
 
-			if err != nil {
-				// XXX
-				slog.Error("Error", "err", err)
-				break
+			if _, ok := pending[port.Name]; ok {
+				// Seen on an earlier page; already being crawled
+				pendingMu.Unlock()
+				continue
 			}
 
-			file := port.DistFiles[group].Items[0]
+			pending[port.Name] = &pendingPort{
+				info:      port,
+				matcher:   version.NewMatcher(port),
+				remaining: len(jobs),
+			}
+			pendingMu.Unlock()
 
-			crawl.In() <- crawler.CrawlJob{
-				Port: port,
-				Site: site,
-				File: file,
+			for _, job := range jobs {
+				crawl.In() <- job
 			}
 		}
-	}
+
+		// The pager stops early if the context is cancelled, and
+		// the crawler answers any jobs sent after that with errors
+		close(crawl.In())
+	}()
 
 	for result := range crawl.Out() {
-		slog.Info("Crawl result", "result", result)
+		pendingMu.Lock()
+
+		p := pending[result.Port]
+
+		if p == nil {
+			pendingMu.Unlock()
+			slog.Warn("Result for port with no pending crawl", "port", result.Port, "site", result.Site)
+			continue
+		}
+
+		if result.Err != nil {
+			slog.Warn("Crawl failed", "port", result.Port, "site", result.Site, "err", result.Err)
+		} else {
+			p.succeeded = true
+			p.candidates = append(p.candidates, p.matcher.Candidates(result.Files)...)
+
+			for _, release := range result.Releases {
+				p.candidates = append(p.candidates, version.Candidate{
+					Version: release.Version,
+					File:    release.File,
+				})
+			}
+		}
+
+		p.remaining--
+
+		if p.remaining > 0 {
+			pendingMu.Unlock()
+			continue
+		}
+
+		delete(pending, result.Port)
+		pendingMu.Unlock()
+
+		if !p.succeeded || ctx.Err() != nil {
+			// Leave the previous outcome in place; after
+			// cancellation we may only have partial results
+			continue
+		}
+
+		accepted, rejected := version.Filter(p.info.Config, p.info.DistVersion, p.candidates)
+
+		for _, r := range rejected {
+			if version.IsNewer(r.Candidate.Version, p.info.DistVersion) {
+				slog.Info("Rejected candidate", "port", p.info.Name, "version", r.Candidate.Version, "reason", r.Reason)
+			}
+		}
+
+		best := version.Newest(accepted, p.info.DistVersion)
+
+		if best != nil {
+			slog.Info("Found new version", "port", p.info.Name, "version", best.Version, "file", best.File)
+			err = db.SetNewVersion(p.info.Name, best.Version, best.File.String())
+		} else {
+			err = db.ClearNewVersion(p.info.Name)
+		}
+
+		if err != nil {
+			slog.Error("Error recording crawl outcome", "port", p.info.Name, "err", err)
+		}
+	}
+
+	err = crawl_lim.Flush()
+
+	if err != nil {
+		slog.Error("Failed to save crawl limiter state", "err", err)
+	}
+
+	if ctx.Err() != nil {
+		slog.Error("Crawl aborted due to context error", "err", ctx.Err())
+		os.Exit(1)
+	}
+}
+
+func retryPolicy(cfg config.RetryPolicy) crawler.RetryPolicy {
+	return crawler.RetryPolicy{
+		MaxAttempts: cfg.MaxAttempts,
+		BaseDelay:   time.Duration(cfg.BaseDelayMs) * time.Millisecond,
+		MaxDelay:    time.Duration(cfg.MaxDelayMs) * time.Millisecond,
 	}
 }
+
+type pendingPort struct {
+	info       types.PortInfo
+	matcher    *version.Matcher
+	remaining  int
+	succeeded  bool
+	candidates []version.Candidate
+}
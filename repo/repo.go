@@ -37,7 +37,7 @@ func getPortName(path string) (*types.PortName, bool) {
 	// case actually happens...)
 	isRoot := (len(frags) == 2) || (len(frags) == 3 && len(frags[2]) == 0)
 
-	portName := types.PortName{frags[0], frags[1]}
+	portName := types.PortName{Category: frags[0], Name: frags[1]}
 
 	return &portName, isRoot
 }
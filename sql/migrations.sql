@@ -0,0 +1,52 @@
+-- Brings a database created from an earlier tables.sql up to date.
+-- Every statement can safely be run again.
+
+ALTER TABLE "ports" ADD COLUMN IF NOT EXISTS "newFile" text;
+ALTER TABLE "ports" ADD COLUMN IF NOT EXISTS "distName" text NOT NULL DEFAULT '';
+ALTER TABLE "ports" ADD COLUMN IF NOT EXISTS "extractSuffix" text NOT NULL DEFAULT '';
+
+CREATE OR REPLACE FUNCTION update_updatedAt_column()
+RETURNS TRIGGER AS $$
+BEGIN
+	-- Check if any column has changed (other than the
+	-- timestamp of the last crawl, which changes daily)
+	IF to_jsonb(NEW) - 'checkedAt' IS DISTINCT FROM to_jsonb(OLD) - 'checkedAt' THEN
+		NEW."updatedAt" = CURRENT_TIMESTAMP;
+	ELSE
+		NEW."updatedAt" = OLD."updatedAt";
+	END IF;
+	RETURN NEW;
+END;
+$$ LANGUAGE plpgsql;
+
+-- GitHub information used to be stored without its account (and
+-- subdirectory), which shared a JSON key and so were both dropped.
+-- Rows like that can't be crawled, so have the next run re-read
+-- the whole ports tree, which rewrites every row.
+UPDATE "repo" SET "lastCommit" = '' WHERE EXISTS (
+	SELECT 1 FROM "ports"
+	WHERE "gitHub" IS NOT NULL AND NOT ("gitHub"::jsonb ? 'account')
+);
+
+ALTER TABLE "hosts" ADD COLUMN IF NOT EXISTS "downUntil" timestamp;
+ALTER TABLE "hosts" ADD COLUMN IF NOT EXISTS "successCount" integer DEFAULT 0 NOT NULL;
+ALTER TABLE "hosts" ADD COLUMN IF NOT EXISTS "failureCount" integer DEFAULT 0 NOT NULL;
+ALTER TABLE "hosts" ADD COLUMN IF NOT EXISTS "consecutiveFailures" integer DEFAULT 0 NOT NULL;
+ALTER TABLE "hosts" ADD COLUMN IF NOT EXISTS "lastError" text;
+ALTER TABLE "hosts" ADD COLUMN IF NOT EXISTS "lastRequestAt" timestamp;
+ALTER TABLE "hosts" ADD COLUMN IF NOT EXISTS "learnedRate" double precision;
+
+CREATE TABLE IF NOT EXISTS "listings" (
+	"url" text NOT NULL,
+	"etag" text,
+	"lastModified" text,
+	"fetchedAt" timestamp DEFAULT CURRENT_TIMESTAMP,
+	UNIQUE ("url")
+);
+
+ALTER TABLE "ports" ADD COLUMN IF NOT EXISTS "forge" text;
+ALTER TABLE "ports" ADD COLUMN IF NOT EXISTS "goModule" text NOT NULL DEFAULT '';
+
+-- Stale "portConfig" values, written before LimitWhich existed (and
+-- so reading as limitw index 0), need no rewriting: port
+-- configurations are derived from the "portscout" column on load.
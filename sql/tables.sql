@@ -2,13 +2,18 @@ CREATE TABLE "ports" (
 	"name" text NOT NULL,
 	"version" text NOT NULL,
 	"newVersion" text,
+	"newFile" text,
 	"category" text NOT NULL,
 	"checkedAt" timestamp,
 	"updatedAt" timestamp DEFAULT CURRENT_TIMESTAMP,
 	"maintainer" text NOT NULL,
+	"distName" text NOT NULL DEFAULT '',
+	"extractSuffix" text NOT NULL DEFAULT '',
 	"masterSites" text NOT NULL,
 	"distFiles" text NOT NULL,
 	"gitHub" text,
+	"forge" text,
+	"goModule" text NOT NULL DEFAULT '',
 	"portscout" text NOT NULL,
 	"portConfig" text NOT NULL,
 	UNIQUE ("category", "name")
@@ -25,9 +30,24 @@ CREATE TABLE "hosts" (
 	"hostname" text,
 	"accessedAt" timestamp DEFAULT CURRENT_TIMESTAMP,
 	"isDown" boolean DEFAULT FALSE NOT NULL,
+	"downUntil" timestamp,
+	"successCount" integer DEFAULT 0 NOT NULL,
+	"failureCount" integer DEFAULT 0 NOT NULL,
+	"consecutiveFailures" integer DEFAULT 0 NOT NULL,
+	"lastError" text,
+	"lastRequestAt" timestamp,
+	"learnedRate" double precision,
 	UNIQUE ("hostname")
 );
 
+CREATE TABLE "listings" (
+	"url" text NOT NULL,
+	"etag" text,
+	"lastModified" text,
+	"fetchedAt" timestamp DEFAULT CURRENT_TIMESTAMP,
+	UNIQUE ("url")
+);
+
 INSERT INTO "repo" (
 	"lastCommit",
 	"syncedAt"
@@ -39,8 +59,9 @@ INSERT INTO "repo" (
 CREATE OR REPLACE FUNCTION update_updatedAt_column()
 RETURNS TRIGGER AS $$
 BEGIN
-	-- Check if any column has changed
-	IF NEW.* IS DISTINCT FROM OLD.* THEN
+	-- Check if any column has changed (other than the
+	-- timestamp of the last crawl, which changes daily)
+	IF to_jsonb(NEW) - 'checkedAt' IS DISTINCT FROM to_jsonb(OLD) - 'checkedAt' THEN
 		NEW."updatedAt" = CURRENT_TIMESTAMP;
 	ELSE
 		NEW."updatedAt" = OLD."updatedAt";
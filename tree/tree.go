@@ -3,27 +3,30 @@ package tree
 import (
 	"bytes"
 	"context"
-	"errors"
 	"fmt"
-	"log/slog"
+	"maps"
 	"net/url"
 	"os/exec"
+	"path"
 	"path/filepath"
-	"regexp"
-	"strconv"
+	"slices"
 	"strings"
 	"sync"
 
 	"github.com/samott/portscout2/types"
 )
 
+// Archive suffixes stripped from distfile names to find tag names.
+var archiveSuffixes = []string{".tar.gz", ".tgz", ".tar.bz2", ".tar.xz", ".zip"}
+
 type Tree struct {
-	makeCmd  string
-	portsDir string
-	sem      chan struct{}
-	maxProc  int
-	in       chan QueryJob
-	out      chan QueryResult
+	makeCmd    string
+	portsDir   string
+	sem        chan struct{}
+	maxProc    int
+	giteaHosts []string
+	in         chan QueryJob
+	out        chan QueryResult
 }
 
 type QueryJob struct {
@@ -35,122 +38,147 @@ type QueryResult struct {
 	Err  error
 }
 
-func parsePortConfig(portscoutStr string) (types.PortConfig, error) {
-	vars := strings.Fields(portscoutStr)
+func NewTree(makeCmd string, portsDir string, maxProc int) *Tree {
+	return &Tree{
+		makeCmd:    makeCmd,
+		portsDir:   portsDir,
+		maxProc:    maxProc,
+		giteaHosts: []string{"codeberg.org"},
+		sem:        make(chan struct{}, maxProc),
+		in:         make(chan QueryJob, maxProc),
+		out:        make(chan QueryResult, maxProc),
+	}
+}
 
-	vmap := make(map[string]string)
+/**
+ * Sets the hosts (path.Match patterns) of Gitea/Forgejo instances
+ * whose master sites are recognised as forge projects. Codeberg is
+ * recognised by default.
+ */
+func (tree *Tree) SetGiteaHosts(patterns []string) {
+	tree.giteaHosts = make([]string, 0, len(patterns))
 
-	cfg := types.PortConfig{
-		IndexSite:    nil,
-		LimitVer:     nil,
-		LimitEven:    false,
-		LimitWhich:   0,
-		SkipBeta:     true,
-		SkipVersions: make([]string, 0),
-		Ignore:       false,
+	for _, pattern := range patterns {
+		tree.giteaHosts = append(tree.giteaHosts, strings.ToLower(pattern))
 	}
+}
 
-	for _, pair := range vars {
-		vals := strings.SplitN(pair, ":", 2)
-
-		if len(vals) != 2 {
-			return cfg, errors.New("Invalid tuple in PORTSCOUT variable")
-		}
+// Returns GitLab project information if the port uses USE_GITLAB.
+func gitLabInfo(use string, site string, account string, project string, tagName string) *types.ForgeInfo {
+	if use == "" {
+		return nil
+	}
 
-		vmap[vals[0]] = vals[1]
+	if site == "" {
+		site = "https://gitlab.com"
 	}
 
-	if val, ok := vmap["site"]; ok {
-		if u, err := url.ParseRequestURI(val); err == nil {
-			cfg.IndexSite = u
-		} else {
-			slog.Warn("Invalid site value in PORTSCOUT variable; ignoring", "site", val)
-		}
+	return &types.ForgeInfo{
+		Type:    types.ForgeGitLab,
+		Site:    strings.TrimSuffix(site, "/"),
+		Account: account,
+		Project: project,
+		TagName: tagName,
 	}
+}
 
-	if val, ok := vmap["limit"]; ok {
-		if re, err := regexp.Compile(val); err == nil {
-			cfg.LimitVer = re
-		} else {
-			slog.Warn("Invalid limit value in PORTSCOUT variable; ignoring", "limit", val)
+/**
+ * Returns the module path of a port built with USES=go: GO_MODULE
+ * if it's set (less any "@version"), otherwise the path implied by
+ * the port's GitHub project, which holds for most Go software.
+ */
+func goModulePath(uses string, goModule string, github *types.GitHubInfo) string {
+	isGo := false
+
+	for _, use := range strings.Fields(uses) {
+		if name, _, _ := strings.Cut(use, ":"); name == "go" {
+			isGo = true
 		}
 	}
 
-	if val, ok := vmap["limitw"]; ok {
-		vals := strings.SplitN(val, ",", 2)
+	if !isGo {
+		return ""
+	}
 
-		which, even, err := (func() (int, bool, error) {
-			even := true
+	if goModule != "" {
+		module, _, _ := strings.Cut(goModule, "@")
+		return module
+	}
 
-			if len(vals) != 2 {
-				return 0, even, errors.New("Invalid limitw tuple")
-			}
+	if github != nil {
+		return "github.com/" + github.Account + "/" + github.Project
+	}
 
-			which, err := strconv.Atoi(vals[0])
+	return ""
+}
 
-			if err != nil {
-				return which, even, errors.New("Invalid limitw index")
+/**
+ * Recognises master sites on Gitea/Forgejo instances, which the
+ * ports framework has no variables for. Two forms are understood:
+ * https://codeberg.org/acct/proj/archive/ (with the tag name taken
+ * from the distfile, e.g. "v1.2.tar.gz") and
+ * https://codeberg.org/acct/proj/releases/download/v1.2/.
+ */
+func (tree *Tree) giteaInfo(sites map[string]*types.TaggedList, files map[string]*types.TaggedList) *types.ForgeInfo {
+	for _, group := range slices.Sorted(maps.Keys(sites)) {
+		for _, item := range sites[group].Items {
+			u, err := url.Parse(item)
+
+			if err != nil || !tree.isGiteaHost(u.Hostname()) {
+				continue
 			}
 
-			evenOdd := strings.ToLower(vals[1])
+			segments := strings.Split(strings.Trim(u.Path, "/"), "/")
 
-			if evenOdd == "even" {
-				even = true
-			} else if evenOdd == "odd" {
-				even = false
-			} else {
-				return which, even, errors.New("Invalid limitw parity")
+			if len(segments) < 3 {
+				continue
 			}
 
-			return which, even, nil
-		})()
+			info := &types.ForgeInfo{
+				Type:    types.ForgeGitea,
+				Site:    u.Scheme + "://" + u.Host,
+				Account: segments[0],
+				Project: segments[1],
+			}
 
-		if err == nil {
-			cfg.LimitWhich = which
-			cfg.LimitEven = even
-		} else {
-			slog.Warn("Invalid limitw value in PORTSCOUT variable; ignoring", "limitw", val)
-		}
-	}
+			switch {
+			case len(segments) == 3 && segments[2] == "archive":
+				if distFiles, ok := files[group]; ok && len(distFiles.Items) > 0 {
+					info.TagName = trimArchiveSuffix(distFiles.Items[0])
+				}
+			case len(segments) == 5 && segments[2] == "releases" && segments[3] == "download":
+				info.TagName = segments[4]
+			default:
+				continue
+			}
 
-	if val, ok := vmap["ignore"]; ok {
-		if val == "1" || val == "true" || val == "yes" {
-			cfg.Ignore = true
-		} else {
-			cfg.Ignore = false
+			return info
 		}
 	}
 
-	if val, ok := vmap["skipb"]; ok {
-		if val == "1" || val == "true" || val == "yes" {
-			cfg.SkipBeta = true
-		} else {
-			cfg.SkipBeta = false
-		}
-	}
+	return nil
+}
 
-	if val, ok := vmap["skipv"]; ok {
-		vers := strings.Split(val, ",")
+func (tree *Tree) isGiteaHost(host string) bool {
+	host = strings.ToLower(host)
 
-		for _, ver := range vers {
-			if trimmed := strings.TrimSpace(ver); trimmed != "" {
-				cfg.SkipVersions = append(cfg.SkipVersions, trimmed)
-			}
+	for _, pattern := range tree.giteaHosts {
+		if matched, _ := path.Match(pattern, host); matched {
+			return true
 		}
 	}
 
-	return cfg, nil
+	return false
 }
 
-func NewTree(makeCmd string, portsDir string, maxProc int) *Tree {
-	return &Tree{
-		makeCmd:  makeCmd,
-		portsDir: portsDir,
-		maxProc:  maxProc,
-		sem:      make(chan struct{}, maxProc),
-		in:       make(chan QueryJob, maxProc),
-		out:      make(chan QueryResult, maxProc),
+func trimArchiveSuffix(file string) string {
+	for _, suffix := range archiveSuffixes {
+		if strings.HasSuffix(file, suffix) {
+			return strings.TrimSuffix(file, suffix)
+		}
 	}
+
+	return file
 }
 
 func (c *Tree) In() chan<- QueryJob {
@@ -168,7 +196,8 @@ func (tree *Tree) QueryPorts(ctx context.Context) {
 		"DISTNAME", "DISTVERSION", "DISTFILES", "EXTRACT_SUFX", "MASTER_SITES",
 		"MASTER_SITE_SUBDIR", "SLAVE_PORT", "MASTER_PORT", "PORTSCOUT",
 		"MAINTAINER", "COMMENT", "USE_GITHUB", "GH_ACCOUNT", "GH_PROJECT",
-		"GH_TAGNAME", "GH_SUBDIR",
+		"GH_TAGNAME", "GH_SUBDIR", "USE_GITLAB", "GL_SITE", "GL_ACCOUNT",
+		"GL_PROJECT", "GL_TAGNAME", "USES", "GO_MODULE",
 	}
 
 	for job := range tree.in {
@@ -239,7 +268,15 @@ func (tree *Tree) QueryPorts(ctx context.Context) {
 				github = nil
 			}
 
-			portConfig, err := parsePortConfig(lines[8])
+			forge := gitLabInfo(lines[16], lines[17], lines[18], lines[19], lines[20])
+
+			if forge == nil {
+				forge = tree.giteaInfo(sites, files)
+			}
+
+			goModule := goModulePath(lines[21], lines[22], github)
+
+			portConfig, err := types.ParsePortConfig(lines[8])
 
 			if err != nil {
 				tree.out <- QueryResult{
@@ -267,6 +304,8 @@ func (tree *Tree) QueryPorts(ctx context.Context) {
 					Maintainer:       lines[9],
 					Comment:          lines[10],
 					GitHub:           github,
+					Forge:            forge,
+					GoModule:         goModule,
 				},
 				Err: nil,
 			}
@@ -2,80 +2,72 @@ package tree
 
 import (
 	"testing"
-)
 
-func TestParsePortConfig(t *testing.T) {
-	result, err := parsePortConfig("site:http://www.x.com skipb:false limitw:1,odd skipv:1.1,1.9")
+	"github.com/samott/portscout2/types"
+)
 
-	if err != nil {
-		t.Fatal("Port config parse error")
+func TestGitLabInfo(t *testing.T) {
+	if gitLabInfo("", "", "acct", "proj", "v1.0") != nil {
+		t.Fatal("Expected no GitLab info without USE_GITLAB")
 	}
 
-	if result.IndexSite.String() != "http://www.x.com" {
-		t.Fatal("Incorrect indexSite value")
-	}
+	info := gitLabInfo("yes", "", "acct", "proj", "v1.0")
 
-	if result.SkipBeta {
-		t.Fatal("Incorrect skipBeta value")
+	if info == nil || info.Type != types.ForgeGitLab || info.Site != "https://gitlab.com" {
+		t.Fatal("Incorrect GitLab info:", info)
 	}
 
-	if result.LimitWhich != 1 || result.LimitEven {
-		t.Fatal("Incorrect limitWhich values")
-	}
+	info = gitLabInfo("yes", "https://gitlab.example.org/", "acct", "proj", "v1.0")
 
-	if len(result.SkipVersions) != 2 {
-		t.Fatal("Incorrect skipVersions count")
+	if info.Site != "https://gitlab.example.org" || info.Account != "acct" || info.TagName != "v1.0" {
+		t.Fatal("Incorrect GitLab info:", info)
 	}
+}
 
-	if result.SkipVersions[0] != "1.1" {
-		t.Fatal("Incorrect skipVersions parse (entry 0)")
-	}
+func TestGiteaInfo(t *testing.T) {
+	tree := NewTree("make", "/usr/ports", 1)
 
-	if result.SkipVersions[1] != "1.9" {
-		t.Fatal("Incorrect skipVersions parse (entry 1)")
-	}
+	info := tree.giteaInfo(
+		types.UnmarshalTaggedLists("https://codeberg.org/acct/proj/archive/"),
+		types.UnmarshalTaggedLists("v1.2.tar.gz"),
+	)
 
-	if result.Ignore {
-		t.Fatal("Incorrect ignore value")
+	if info == nil || info.Type != types.ForgeGitea || info.Site != "https://codeberg.org" {
+		t.Fatal("Incorrect Gitea info:", info)
 	}
 
-	result2, err := parsePortConfig("skipb:true limitw:2,even skipv:2.234 ignore:true")
-
-	if err != nil {
-		t.Fatal("Port config parse error")
+	if info.Account != "acct" || info.Project != "proj" || info.TagName != "v1.2" {
+		t.Fatal("Incorrect Gitea project:", info)
 	}
 
-	if result2.IndexSite != nil {
-		t.Fatal("Incorrect indexSite value")
-	}
+	sites := types.UnmarshalTaggedLists("https://git.example.org/acct/proj/releases/download/proj-1.2/")
+	files := types.UnmarshalTaggedLists("proj-1.2.tar.xz")
 
-	if !result2.SkipBeta {
-		t.Fatal("Incorrect skipBeta value")
+	if tree.giteaInfo(sites, files) != nil {
+		t.Fatal("Unexpected Gitea info for unknown host")
 	}
 
-	if result2.LimitWhich != 2 || !result2.LimitEven {
-		t.Fatal("Incorrect limitWhich values")
-	}
+	tree.SetGiteaHosts([]string{"git.example.*"})
 
-	if len(result2.SkipVersions) != 1 {
-		t.Fatal("Incorrect skipVersions count")
-	}
+	info = tree.giteaInfo(sites, files)
 
-	if result2.SkipVersions[0] != "2.234" {
-		t.Fatal("Incorrect skipVersions parse (entry 0)")
+	if info == nil || info.TagName != "proj-1.2" {
+		t.Fatal("Incorrect Gitea release info:", info)
 	}
+}
 
-	if !result2.Ignore {
-		t.Fatal("Incorrect ignore value")
-	}
+func TestGoModulePath(t *testing.T) {
+	github := &types.GitHubInfo{Account: "acct", Project: "tool"}
 
-	result3, err := parsePortConfig("limit:^[0-9.]+$")
+	if module := goModulePath("cpe go:modules", "", github); module != "github.com/acct/tool" {
+		t.Fatal("Incorrect module from GitHub:", module)
+	}
 
-	if !result3.LimitVer.MatchString("1.234") {
-		t.Fatal("Regexp positive match failed");
+	if module := goModulePath("go", "example.org/tool/v2@v2.0.1", github); module != "example.org/tool/v2" {
+		t.Fatal("Incorrect module from GO_MODULE:", module)
 	}
 
-	if result3.LimitVer.MatchString("a1.234") {
-		t.Fatal("Regexp negative match failed");
+	if module := goModulePath("cargo gmake", "", github); module != "" {
+		t.Fatal("Unexpected module for non-Go port:", module)
 	}
 }
@@ -0,0 +1,127 @@
+package types
+
+import (
+	"errors"
+	"log/slog"
+	"net/url"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+var sitePlaceholder = regexp.MustCompile(`%([A-Z]+)%`)
+
+/**
+ * Parses a port's PORTSCOUT variable. Unset options take their
+ * defaults, so no limitw restriction is a LimitWhich of -1.
+ */
+func ParsePortConfig(portscoutStr string) (PortConfig, error) {
+	vars := strings.Fields(portscoutStr)
+
+	vmap := make(map[string]string)
+
+	cfg := PortConfig{
+		IndexSite:    nil,
+		LimitVer:     nil,
+		LimitEven:    false,
+		LimitWhich:   -1,
+		SkipBeta:     true,
+		SkipVersions: make([]string, 0),
+		Ignore:       false,
+	}
+
+	for _, pair := range vars {
+		vals := strings.SplitN(pair, ":", 2)
+
+		if len(vals) != 2 {
+			return cfg, errors.New("Invalid tuple in PORTSCOUT variable")
+		}
+
+		vmap[vals[0]] = vals[1]
+	}
+
+	if val, ok := vmap["site"]; ok {
+		// Placeholders such as %VERSION% aren't valid URL escapes,
+		// so escape them; they are substituted when crawling
+		val = sitePlaceholder.ReplaceAllString(val, "%25$1%25")
+
+		if u, err := url.ParseRequestURI(val); err == nil {
+			cfg.IndexSite = u
+		} else {
+			slog.Warn("Invalid site value in PORTSCOUT variable; ignoring", "site", val)
+		}
+	}
+
+	if val, ok := vmap["limit"]; ok {
+		if re, err := regexp.Compile(val); err == nil {
+			cfg.LimitVer = re
+		} else {
+			slog.Warn("Invalid limit value in PORTSCOUT variable; ignoring", "limit", val)
+		}
+	}
+
+	if val, ok := vmap["limitw"]; ok {
+		vals := strings.SplitN(val, ",", 2)
+
+		which, even, err := (func() (int, bool, error) {
+			even := true
+
+			if len(vals) != 2 {
+				return 0, even, errors.New("Invalid limitw tuple")
+			}
+
+			which, err := strconv.Atoi(vals[0])
+
+			if err != nil {
+				return which, even, errors.New("Invalid limitw index")
+			}
+
+			evenOdd := strings.ToLower(vals[1])
+
+			if evenOdd == "even" {
+				even = true
+			} else if evenOdd == "odd" {
+				even = false
+			} else {
+				return which, even, errors.New("Invalid limitw parity")
+			}
+
+			return which, even, nil
+		})()
+
+		if err == nil {
+			cfg.LimitWhich = which
+			cfg.LimitEven = even
+		} else {
+			slog.Warn("Invalid limitw value in PORTSCOUT variable; ignoring", "limitw", val)
+		}
+	}
+
+	if val, ok := vmap["ignore"]; ok {
+		if val == "1" || val == "true" || val == "yes" {
+			cfg.Ignore = true
+		} else {
+			cfg.Ignore = false
+		}
+	}
+
+	if val, ok := vmap["skipb"]; ok {
+		if val == "1" || val == "true" || val == "yes" {
+			cfg.SkipBeta = true
+		} else {
+			cfg.SkipBeta = false
+		}
+	}
+
+	if val, ok := vmap["skipv"]; ok {
+		vers := strings.Split(val, ",")
+
+		for _, ver := range vers {
+			if trimmed := strings.TrimSpace(ver); trimmed != "" {
+				cfg.SkipVersions = append(cfg.SkipVersions, trimmed)
+			}
+		}
+	}
+
+	return cfg, nil
+}
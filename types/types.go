@@ -20,14 +20,32 @@ type GitHubInfo struct {
 	Account string `json:"account"`
 	Project string `json:"project"`
 	TagName string `json:"tagName"`
-	SubDir  string `json:"account"`
+	SubDir  string `json:"subDir"`
+}
+
+const (
+	ForgeGitLab = "gitlab"
+	ForgeGitea  = "gitea"
+)
+
+/**
+ * A project on a GitLab or Gitea/Forgejo instance (such as
+ * Codeberg) from which the port is fetched. Site is the instance's
+ * base URL, e.g. "https://gitlab.com".
+ */
+type ForgeInfo struct {
+	Type    string `json:"type"`
+	Site    string `json:"site"`
+	Account string `json:"account"`
+	Project string `json:"project"`
+	TagName string `json:"tagName"`
 }
 
 type PortConfig struct {
 	IndexSite    *url.URL
 	LimitVer     *regexp.Regexp
 	LimitEven    bool
-	LimitWhich   int
+	LimitWhich   int // -1 if no limitw restriction
 	SkipBeta     bool
 	SkipVersions []string
 	Ignore       bool
@@ -51,6 +69,8 @@ type PortInfo struct {
 	Maintainer       string
 	Comment          string
 	GitHub           *GitHubInfo
+	Forge            *ForgeInfo
+	GoModule         string
 	Config           PortConfig
 }
 
@@ -64,6 +84,46 @@ type PortUpdate struct {
 	CheckedAt  *time.Time `json:"checkedAt"`
 }
 
+// AccessedAt is the time of the last successful crawl. DownUntil
+// is when a host marked down becomes eligible to be tried again.
+type HostStatus struct {
+	Hostname            string
+	AccessedAt          *time.Time
+	IsDown              bool
+	DownUntil           *time.Time
+	SuccessCount        uint
+	FailureCount        uint
+	ConsecutiveFailures uint
+	LastError           string
+}
+
+// Rate limiter state carried over between runs. LearnedRate is in
+// requests per second, and zero if the host's configured rate
+// applies.
+type HostRateState struct {
+	Hostname    string
+	LastAccess  *time.Time
+	LearnedRate float64
+}
+
+// A parsed directory listing, with the validators the server sent
+// for use in conditional requests.
+type Listing struct {
+	Url          string              `json:"url"`
+	ETag         string              `json:"etag"`
+	LastModified string              `json:"lastModified"`
+	Files        []string            `json:"files"`
+	FileInfo     map[string]FileInfo `json:"fileInfo,omitempty"`
+	Dirs         []string            `json:"dirs"`
+}
+
+// What a directory listing said about a file. Size is -1 and
+// ModTime zero where the listing didn't say.
+type FileInfo struct {
+	Size    int64     `json:"size"`
+	ModTime time.Time `json:"modTime"`
+}
+
 type MaintainerStats struct {
 	Maintainer       string `json:"maintainer"`
 	TotalPortCount   uint   `json:"totalPortCount"`
@@ -0,0 +1,236 @@
+package version
+
+import (
+	"strconv"
+	"strings"
+)
+
+// Values for the special pre-release stage strings. They sort in
+// the same order as their initial letters, so that e.g. "1.0a1"
+// and "1.0alpha1" compare equally.
+var stages = map[string]int{
+	"alpha": 'a' - 'a' + 1,
+	"beta":  'b' - 'a' + 1,
+	"pre":   'p' - 'a' + 1,
+	"rc":    'r' - 'a' + 1,
+}
+
+type component struct {
+	n  int64
+	a  int
+	pl int64
+}
+
+/**
+ * Compares two version strings using the same ordering as
+ * FreeBSD's pkg_version, returning -1, 0 or 1 if a is older
+ * than, equal to or newer than b respectively.
+ *
+ * Versions are split into components on any non-alphanumeric
+ * character. Each component is a number, optionally followed
+ * by a letter and a further number ("1a2"). A component which
+ * starts with a letter has no numeric part and sorts before any
+ * component that does, so the well-known pre-release stages
+ * "alpha", "beta", "pre" and "rc" (and single letters following
+ * a separator, like "1.0.b2") mark a version as older than the
+ * release. A stage string directly after a number starts a new
+ * component, so that "1.0rc1" is read as "1.0.rc1"; other
+ * letters directly after a number ("1.0a") belong to the same
+ * component and sort after it. "pl" (patch level) is equivalent
+ * to "." and "*" sorts before everything.
+ *
+ * Missing trailing components are treated as zero, so "1.0"
+ * and "1.0.0" are equal.
+ *
+ * A PORTEPOCH (",N") outranks everything else in the version,
+ * and a PORTREVISION ("_N") is only considered when the rest of
+ * the version is equal.
+ */
+func Compare(a string, b string) int {
+	aVer, aEpoch, aRev := splitVersion(a)
+	bVer, bEpoch, bRev := splitVersion(b)
+
+	if cmp := compareInt(aEpoch, bEpoch); cmp != 0 {
+		return cmp
+	}
+
+	for aVer != "" || bVer != "" {
+		var aComp, bComp component
+
+		aComp, aVer = nextComponent(aVer)
+		bComp, bVer = nextComponent(bVer)
+
+		if cmp := compareInt(aComp.n, bComp.n); cmp != 0 {
+			return cmp
+		}
+
+		if cmp := compareInt(int64(aComp.a), int64(bComp.a)); cmp != 0 {
+			return cmp
+		}
+
+		if cmp := compareInt(aComp.pl, bComp.pl); cmp != 0 {
+			return cmp
+		}
+	}
+
+	return compareInt(aRev, bRev)
+}
+
+// IsNewer reports whether candidate is a newer version than current.
+func IsNewer(candidate string, current string) bool {
+	return Compare(candidate, current) > 0
+}
+
+/**
+ * Separates a version into its main part, PORTEPOCH and
+ * PORTREVISION. Suffixes which aren't purely numeric are
+ * left in place as part of the version.
+ */
+func splitVersion(ver string) (string, int64, int64) {
+	var epoch, revision int64
+
+	if i := strings.LastIndexByte(ver, ','); i >= 0 && isNumeric(ver[i+1:]) {
+		epoch = parseNumber(ver[i+1:])
+		ver = ver[:i]
+	}
+
+	if i := strings.LastIndexByte(ver, '_'); i >= 0 && isNumeric(ver[i+1:]) {
+		revision = parseNumber(ver[i+1:])
+		ver = ver[:i]
+	}
+
+	return ver, epoch, revision
+}
+
+/**
+ * Parses one component from the start of ver, returning it along
+ * with the remainder of the string. An empty string yields a zero
+ * component.
+ */
+func nextComponent(ver string) (component, string) {
+	var comp component
+
+	ver = skipSeparators(ver)
+
+	if ver == "" {
+		return comp, ver
+	}
+
+	hasStage := false
+
+	switch {
+	case isDigit(ver[0]):
+		end := digitsEnd(ver)
+		comp.n = parseNumber(ver[:end])
+		ver = ver[end:]
+	case ver[0] == '*':
+		comp.n = -2
+		ver = ver[1:]
+	default:
+		comp.n = -1
+		hasStage = true
+	}
+
+	if ver != "" && isLetter(ver[0]) {
+		word := strings.ToLower(ver[:lettersEnd(ver)])
+
+		if value, ok := stages[word]; ok {
+			if !hasStage {
+				// The stage marks the end of this component
+				// and is picked up as the start of the next
+				return comp, ver
+			}
+
+			comp.a = value
+		} else if word == "pl" {
+			// Patch level is treated as a separator
+			return comp, ver
+		} else {
+			comp.a = int(word[0]-'a') + 1
+		}
+
+		ver = ver[len(word):]
+
+		if ver != "" && isDigit(ver[0]) {
+			end := digitsEnd(ver)
+			comp.pl = parseNumber(ver[:end])
+			ver = ver[end:]
+		} else {
+			comp.pl = -1
+		}
+	}
+
+	return comp, ver
+}
+
+func skipSeparators(ver string) string {
+	for ver != "" {
+		if isDigit(ver[0]) || ver[0] == '*' {
+			break
+		}
+
+		if isLetter(ver[0]) {
+			if end := lettersEnd(ver); strings.ToLower(ver[:end]) == "pl" {
+				ver = ver[end:]
+				continue
+			}
+
+			break
+		}
+
+		ver = ver[1:]
+	}
+
+	return ver
+}
+
+func compareInt(a int64, b int64) int {
+	if a < b {
+		return -1
+	}
+
+	if a > b {
+		return 1
+	}
+
+	return 0
+}
+
+func parseNumber(s string) int64 {
+	n, err := strconv.ParseInt(s, 10, 64)
+
+	if err != nil {
+		// Out of range; saturate rather than wrap
+		return 1<<63 - 1
+	}
+
+	return n
+}
+
+func digitsEnd(s string) int {
+	i := 0
+
+	for i < len(s) && isDigit(s[i]) {
+		i++
+	}
+
+	return i
+}
+
+func lettersEnd(s string) int {
+	i := 0
+
+	for i < len(s) && isLetter(s[i]) {
+		i++
+	}
+
+	return i
+}
+
+func isDigit(c byte) bool {
+	return c >= '0' && c <= '9'
+}
+
+func isLetter(c byte) bool {
+	return (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z')
+}
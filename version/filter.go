@@ -0,0 +1,111 @@
+package version
+
+import (
+	"errors"
+	"regexp"
+	"slices"
+
+	"github.com/samott/portscout2/types"
+)
+
+var (
+	ErrLimitVer   = errors.New("version does not match limit pattern")
+	ErrLimitWhich = errors.New("version fails limitw parity rule")
+	ErrBeta       = errors.New("version looks like a pre-release")
+	ErrSkipped    = errors.New("version is listed in skipv")
+)
+
+// Pre-release markers: either a well-known word, or a lone "a" or
+// "b" between numbers ("1.0b2"). A bare trailing letter ("1.1.1k")
+// is usually a release in its own right, so isn't included.
+var betaRegex = regexp.MustCompile(
+	`(?i)(?:(?:^|[^a-z])(?:alpha|beta|pre|rc|dev|snap|snapshot|test|nightly)(?:[^a-z]|$)|[0-9][ab][0-9])`,
+)
+
+type Rejection struct {
+	Candidate Candidate
+	Reason    error
+}
+
+/**
+ * Checks a version against the restrictions in a port's PORTSCOUT
+ * configuration, returning one of the Err* values above if it
+ * should be disregarded, or nil if it's acceptable.
+ *
+ * Pre-releases are only rejected if the current version isn't a
+ * pre-release itself, since in that case the maintainer is evidently
+ * tracking them.
+ */
+func Check(cfg types.PortConfig, current string, ver string) error {
+	if slices.Contains(cfg.SkipVersions, ver) {
+		return ErrSkipped
+	}
+
+	if cfg.LimitVer != nil && !cfg.LimitVer.MatchString(ver) {
+		return ErrLimitVer
+	}
+
+	if cfg.LimitWhich >= 0 && !checkParity(ver, cfg.LimitWhich, cfg.LimitEven) {
+		return ErrLimitWhich
+	}
+
+	if cfg.SkipBeta && IsBeta(ver) && !IsBeta(current) {
+		return ErrBeta
+	}
+
+	return nil
+}
+
+/**
+ * Splits candidates into those permitted by the port configuration
+ * and those which aren't, along with the reason for rejection.
+ */
+func Filter(cfg types.PortConfig, current string, candidates []Candidate) ([]Candidate, []Rejection) {
+	accepted := make([]Candidate, 0, len(candidates))
+	rejected := make([]Rejection, 0)
+
+	for _, candidate := range candidates {
+		if err := Check(cfg, current, candidate.Version); err != nil {
+			rejected = append(rejected, Rejection{
+				Candidate: candidate,
+				Reason:    err,
+			})
+			continue
+		}
+
+		accepted = append(accepted, candidate)
+	}
+
+	return accepted, rejected
+}
+
+// IsBeta reports whether a version appears to be a pre-release.
+func IsBeta(ver string) bool {
+	return betaRegex.MatchString(ver)
+}
+
+/**
+ * Checks whether the numeric component at the given (zero-based)
+ * index is even or odd as required. Versions without enough
+ * numeric components pass, as there's nothing to check.
+ */
+func checkParity(ver string, which int, even bool) bool {
+	nums := make([]int64, 0)
+
+	for i := 0; i < len(ver); {
+		if !isDigit(ver[i]) {
+			i++
+			continue
+		}
+
+		end := i + digitsEnd(ver[i:])
+		nums = append(nums, parseNumber(ver[i:end]))
+		i = end
+	}
+
+	if which >= len(nums) {
+		return true
+	}
+
+	return (nums[which]%2 == 0) == even
+}
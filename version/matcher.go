@@ -0,0 +1,169 @@
+package version
+
+import (
+	"regexp"
+	"strings"
+
+	"github.com/samott/portscout2/types"
+)
+
+// Archive suffixes we consider interchangeable when matching
+// candidate files; upstreams commonly switch compression formats
+// between releases.
+var archiveSuffixes = []string{
+	".tar.gz", ".tar.bz2", ".tar.xz", ".tar.zst", ".tar.lz", ".tar.lzma",
+	".tgz", ".tbz2", ".tbz", ".txz", ".tar", ".zip", ".7z", ".gz", ".bz2",
+	".xz",
+}
+
+// A version starts with a digit and may contain dashes only when
+// followed by another number or a pre-release stage, so that e.g.
+// platform names in binary distfiles ("-win64") aren't swallowed.
+// The quantifiers are lazy so that the archive suffix isn't.
+const versionPattern = `([0-9][0-9A-Za-z.+~_]*?(?:-(?:[0-9]|alpha|beta|pre|rc)[0-9A-Za-z.+~_]*?)*?)`
+
+type Matcher struct {
+	patterns []*regexp.Regexp
+}
+
+/**
+ * Creates a matcher for the given port which can extract version
+ * strings from candidate filenames.
+ *
+ * Patterns are derived from the port's DistFiles and from its
+ * DistName (combined with ExtractSuffix) by replacing each
+ * instance of the current DistVersion with a capture group. The
+ * archive suffix is relaxed so that e.g. a port fetching .tar.gz
+ * files also recognises a newer release shipped as .tar.xz.
+ */
+func NewMatcher(port types.PortInfo) *Matcher {
+	m := &Matcher{
+		patterns: make([]*regexp.Regexp, 0),
+	}
+
+	if port.DistVersion == "" {
+		return m
+	}
+
+	templates := make([]string, 0)
+
+	for _, list := range port.DistFiles {
+		templates = append(templates, list.Items...)
+	}
+
+	if port.DistName != "" {
+		templates = append(templates, port.DistName+port.ExtractSuffix)
+	}
+
+	seen := make(map[string]bool)
+
+	for _, template := range templates {
+		expr, ok := templateToPattern(template, port.DistVersion, port.ExtractSuffix)
+
+		if !ok || seen[expr] {
+			continue
+		}
+
+		seen[expr] = true
+
+		if re, err := regexp.Compile(expr); err == nil {
+			m.patterns = append(m.patterns, re)
+		}
+	}
+
+	return m
+}
+
+/**
+ * Attempts to extract a version from a bare filename (not a path
+ * or URL), returning false if the file doesn't look like a
+ * distfile for this port.
+ */
+func (m *Matcher) Match(file string) (string, bool) {
+	for _, re := range m.patterns {
+		matches := re.FindStringSubmatch(file)
+
+		if matches == nil {
+			continue
+		}
+
+		// Where the version appears more than once in the template,
+		// all instances must agree
+		ver := matches[1]
+		consistent := true
+
+		for _, other := range matches[2:] {
+			if other != ver {
+				consistent = false
+				break
+			}
+		}
+
+		if consistent {
+			return ver, true
+		}
+	}
+
+	return "", false
+}
+
+func templateToPattern(template string, ver string, extractSuffix string) (string, bool) {
+	if !strings.Contains(template, ver) {
+		return "", false
+	}
+
+	suffix := ""
+	lower := strings.ToLower(template)
+
+	if extractSuffix != "" && strings.HasSuffix(template, extractSuffix) {
+		suffix = extractSuffix
+	} else {
+		for _, s := range archiveSuffixes {
+			if strings.HasSuffix(lower, s) {
+				suffix = template[len(template)-len(s):]
+				break
+			}
+		}
+	}
+
+	stem := template[:len(template)-len(suffix)]
+
+	if !strings.Contains(stem, ver) {
+		return "", false
+	}
+
+	parts := strings.Split(stem, ver)
+	quoted := make([]string, len(parts))
+
+	for i, part := range parts {
+		quoted[i] = regexp.QuoteMeta(part)
+	}
+
+	expr := "(?i)^" + strings.Join(quoted, versionPattern)
+
+	if suffix != "" {
+		alternatives := make([]string, 0, len(archiveSuffixes))
+
+		for _, s := range archiveSuffixes {
+			alternatives = append(alternatives, regexp.QuoteMeta(s))
+		}
+
+		if !isArchiveSuffix(suffix) {
+			alternatives = append(alternatives, regexp.QuoteMeta(suffix))
+		}
+
+		expr += "(?:" + strings.Join(alternatives, "|") + ")"
+	}
+
+	return expr + "$", true
+}
+
+func isArchiveSuffix(suffix string) bool {
+	for _, s := range archiveSuffixes {
+		if strings.EqualFold(s, suffix) {
+			return true
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,152 @@
+package version
+
+import (
+	"fmt"
+	"strings"
+)
+
+/**
+ * Parses a CPAN version into its dotted-integer form, as Perl's
+ * version module does. A version with a leading "v" or more than
+ * one dot is dotted already ("v1.2.3"); anything else is a decimal,
+ * whose fraction is read in groups of three digits, so that "1.23"
+ * is v1.230 and "1.0203" is v1.20.300. Underscores, which mark
+ * developer releases, are ignored ("1.23_01" is 1.2301).
+ */
+func parsePerl(ver string) ([]int64, bool) {
+	ver = strings.ReplaceAll(ver, "_", "")
+
+	if ver == "" {
+		return nil, false
+	}
+
+	if isPerlDotted(ver) {
+		parts := strings.Split(strings.TrimPrefix(ver, "v"), ".")
+		nums := make([]int64, 0, len(parts))
+
+		for _, part := range parts {
+			if !isNumeric(part) {
+				return nil, false
+			}
+
+			nums = append(nums, parseNumber(part))
+		}
+
+		return nums, true
+	}
+
+	whole, frac, _ := strings.Cut(ver, ".")
+
+	if !isNumeric(whole) || (frac != "" && !isNumeric(frac)) {
+		return nil, false
+	}
+
+	nums := []int64{parseNumber(whole)}
+
+	for frac != "" {
+		if len(frac) < 3 {
+			frac += zeros(3 - len(frac))
+		}
+
+		nums = append(nums, parseNumber(frac[:3]))
+		frac = frac[3:]
+	}
+
+	return nums, true
+}
+
+func isPerlDotted(ver string) bool {
+	return strings.HasPrefix(ver, "v") || strings.Count(ver, ".") > 1
+}
+
+/**
+ * Compares two CPAN versions the way Perl does, so that decimal
+ * and dotted versions can be compared with each other. Note that
+ * as decimals, "1.3" is newer than "1.23". Versions which can't be
+ * parsed are compared as ordinary port versions.
+ */
+func ComparePerl(a string, b string) int {
+	aNums, aOk := parsePerl(a)
+	bNums, bOk := parsePerl(b)
+
+	if !aOk || !bOk {
+		return Compare(a, b)
+	}
+
+	for i := 0; i < len(aNums) || i < len(bNums); i++ {
+		var aNum, bNum int64
+
+		if i < len(aNums) {
+			aNum = aNums[i]
+		}
+
+		if i < len(bNums) {
+			bNum = bNums[i]
+		}
+
+		if cmp := compareInt(aNum, bNum); cmp != 0 {
+			return cmp
+		}
+	}
+
+	return 0
+}
+
+/**
+ * Expresses a CPAN version in the same convention as a port's
+ * current version, so that it compares correctly with Compare:
+ * decimals are converted to dotted form for ports which use it,
+ * and vice versa, and decimals are padded to at least as many
+ * places as the current version ("1.3" becomes "1.30" next to
+ * "1.23"). This only holds for versions which are no older than
+ * the current one by ComparePerl.
+ */
+func PerlVersion(ver string, current string) string {
+	nums, ok := parsePerl(ver)
+
+	if !ok {
+		return ver
+	}
+
+	if isPerlDotted(current) {
+		for len(nums) < 3 {
+			nums = append(nums, 0)
+		}
+
+		parts := make([]string, 0, len(nums))
+
+		for _, num := range nums {
+			parts = append(parts, fmt.Sprint(num))
+		}
+
+		prefix := ""
+
+		if strings.HasPrefix(current, "v") {
+			prefix = "v"
+		}
+
+		return prefix + strings.Join(parts, ".")
+	}
+
+	var frac string
+
+	if isPerlDotted(ver) {
+		for _, num := range nums[1:] {
+			frac += fmt.Sprintf("%03d", num)
+		}
+	} else {
+		_, frac, _ = strings.Cut(strings.ReplaceAll(ver, "_", ""), ".")
+	}
+
+	_, currentFrac, _ := strings.Cut(current, ".")
+
+	if len(frac) < len(currentFrac) {
+		frac += zeros(len(currentFrac) - len(frac))
+	}
+
+	if frac == "" {
+		return fmt.Sprint(nums[0])
+	}
+
+	return fmt.Sprint(nums[0]) + "." + frac
+}
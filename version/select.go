@@ -0,0 +1,54 @@
+package version
+
+import (
+	"net/url"
+	"path"
+)
+
+type Candidate struct {
+	Version string
+	File    *url.URL
+}
+
+/**
+ * Extracts a candidate version from each file in a crawled list
+ * which the matcher recognises as one of the port's distfiles.
+ */
+func (m *Matcher) Candidates(files []*url.URL) []Candidate {
+	candidates := make([]Candidate, 0)
+
+	for _, file := range files {
+		ver, ok := m.Match(path.Base(file.Path))
+
+		if !ok {
+			continue
+		}
+
+		candidates = append(candidates, Candidate{
+			Version: ver,
+			File:    file,
+		})
+	}
+
+	return candidates
+}
+
+/**
+ * Returns the newest of the candidates if it is newer than the
+ * current version, or nil otherwise.
+ */
+func Newest(candidates []Candidate, current string) *Candidate {
+	var best *Candidate
+
+	for i := range candidates {
+		if !IsNewer(candidates[i].Version, current) {
+			continue
+		}
+
+		if best == nil || IsNewer(candidates[i].Version, best.Version) {
+			best = &candidates[i]
+		}
+	}
+
+	return best
+}
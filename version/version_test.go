@@ -0,0 +1,405 @@
+package version
+
+import (
+	"net/url"
+	"regexp"
+	"testing"
+
+	"github.com/samott/portscout2/types"
+)
+
+func TestCompare(t *testing.T) {
+	cases := []struct {
+		a        string
+		b        string
+		expected int
+	}{
+		// Plain numbers
+		{"1.0", "1.0", 0},
+		{"1.0", "1.1", -1},
+		{"1.10", "1.9", 1},
+		{"2", "1.99", 1},
+		{"1.0", "1.0.0", 0},
+		{"1.0.1", "1.0", 1},
+		{"1.0.0.0.1", "1.0", 1},
+		{"20240101", "20231231", 1},
+		{"0.9", "0.10", -1},
+		{"1.2.3", "1-2-3", 0},
+		{"1.2.3", "1.2_3", 1},
+
+		// Pre-release stages
+		{"1.0alpha1", "1.0", -1},
+		{"1.0beta1", "1.0", -1},
+		{"1.0pre1", "1.0", -1},
+		{"1.0rc1", "1.0", -1},
+		{"1.0.rc1", "1.0rc1", 0},
+		{"1.0alpha1", "1.0beta1", -1},
+		{"1.0beta2", "1.0pre1", -1},
+		{"1.0pre2", "1.0rc1", -1},
+		{"1.0rc1", "1.0rc2", -1},
+		{"1.0rc10", "1.0rc9", 1},
+		{"1.0a1", "1.0.alpha1", 1},
+		{"1.0.a1", "1.0.alpha1", 0},
+		{"1.0.b2", "1.0.beta2", 0},
+		{"1.0RC1", "1.0rc1", 0},
+		{"1.0rc1", "0.9", 1},
+		{"1.0beta", "1.0beta1", -1},
+		{"1.0-rc1", "1.0", -1},
+
+		// Letters following a number
+		{"1.0a", "1.0", 1},
+		{"1.0a", "1.0b", -1},
+		{"1.0b", "1.0a2", 1},
+		{"1.1.1k", "1.1.1j", 1},
+		{"1.0.a", "1.0", -1},
+		{"1.0.a", "1.0a", -1},
+
+		// Patch level
+		{"1.0pl1", "1.0", 1},
+		{"1.0pl1", "1.0.1", 0},
+		{"1.0pl2", "1.0pl1", 1},
+		{"1.0pl1", "1.0.2", -1},
+
+		// Wildcard
+		{"1.*", "1.0", -1},
+		{"1.*", "1.a", -1},
+
+		// PORTEPOCH
+		{"1.0,1", "2.0", 1},
+		{"1.0,1", "1.0,2", -1},
+		{"2.0,1", "1.0,1", 1},
+		{"1.0,0", "1.0", 0},
+
+		// PORTREVISION
+		{"1.0_1", "1.0", 1},
+		{"1.0_1", "1.0_2", -1},
+		{"1.1", "1.0_9", 1},
+		{"1.0_1,1", "1.0,1", 1},
+		{"1.0_9", "1.0,1", -1},
+
+		// Edge cases
+		{"", "", 0},
+		{"", "0", 0},
+		{"", "1", -1},
+		{"99999999999999999999", "1", 1},
+	}
+
+	for _, tc := range cases {
+		if result := Compare(tc.a, tc.b); result != tc.expected {
+			t.Errorf("Compare(%q, %q) = %d; expected %d", tc.a, tc.b, result, tc.expected)
+		}
+
+		if result := Compare(tc.b, tc.a); result != -tc.expected {
+			t.Errorf("Compare(%q, %q) = %d; expected %d", tc.b, tc.a, result, -tc.expected)
+		}
+	}
+}
+
+func TestIsNewer(t *testing.T) {
+	if !IsNewer("1.1", "1.0") {
+		t.Fatal("1.1 should be newer than 1.0")
+	}
+
+	if IsNewer("1.0", "1.0") {
+		t.Fatal("1.0 should not be newer than itself")
+	}
+}
+
+func TestMatcher(t *testing.T) {
+	port := types.PortInfo{
+		DistName:      "foo-1.2.3",
+		DistVersion:   "1.2.3",
+		ExtractSuffix: ".tar.gz",
+		DistFiles: map[string]*types.TaggedList{
+			"": &types.TaggedList{
+				Items: []string{"foo-1.2.3.tar.gz"},
+			},
+			"docs": &types.TaggedList{
+				Items: []string{"foo-docs-1.2.3.zip"},
+			},
+		},
+	}
+
+	m := NewMatcher(port)
+
+	cases := []struct {
+		file     string
+		expected string
+		ok       bool
+	}{
+		{"foo-1.2.3.tar.gz", "1.2.3", true},
+		{"foo-1.2.4.tar.gz", "1.2.4", true},
+		{"foo-1.3.tar.xz", "1.3", true},
+		{"foo-2.0.0.tgz", "2.0.0", true},
+		{"foo-2.0.0.zip", "2.0.0", true},
+		{"FOO-2.0.0.tar.gz", "2.0.0", true},
+		{"foo-2.0rc1.tar.gz", "2.0rc1", true},
+		{"foo-2.0-rc1.tar.gz", "2.0-rc1", true},
+		{"foo-2.0-1.tar.gz", "2.0-1", true},
+		{"foo-docs-1.3.zip", "1.3", true},
+		{"foo-2.0-win64.zip", "", false},
+		{"foo-2.0.tar.gz.sig", "", false},
+		{"foo-2.0.tar.gz.asc", "", false},
+		{"foobar-2.0.tar.gz", "", false},
+		{"foo-latest.tar.gz", "", false},
+		{"bar-2.0.tar.gz", "", false},
+		{"foo-2.0.exe", "", false},
+	}
+
+	for _, tc := range cases {
+		ver, ok := m.Match(tc.file)
+
+		if ok != tc.ok || ver != tc.expected {
+			t.Errorf("Match(%q) = %q, %v; expected %q, %v", tc.file, ver, ok, tc.expected, tc.ok)
+		}
+	}
+}
+
+func TestMatcherRepeatedVersion(t *testing.T) {
+	port := types.PortInfo{
+		DistVersion: "3.1",
+		DistFiles: map[string]*types.TaggedList{
+			"": &types.TaggedList{
+				Items: []string{"bar-3.1-src-3.1.tar.bz2"},
+			},
+		},
+	}
+
+	m := NewMatcher(port)
+
+	if ver, ok := m.Match("bar-3.2-src-3.2.tar.bz2"); !ok || ver != "3.2" {
+		t.Fatal("Repeated version not matched:", ver)
+	}
+
+	if _, ok := m.Match("bar-3.2-src-3.3.tar.bz2"); ok {
+		t.Fatal("Inconsistent repeated version matched")
+	}
+}
+
+func TestMatcherNoVersion(t *testing.T) {
+	m := NewMatcher(types.PortInfo{
+		DistName: "foo",
+		DistFiles: map[string]*types.TaggedList{
+			"": &types.TaggedList{
+				Items: []string{"foo.tar.gz"},
+			},
+		},
+	})
+
+	if _, ok := m.Match("foo-1.0.tar.gz"); ok {
+		t.Fatal("Matched without a current version")
+	}
+}
+
+func TestMatcherNonArchiveSuffix(t *testing.T) {
+	m := NewMatcher(types.PortInfo{
+		DistName:      "baz-0.9",
+		DistVersion:   "0.9",
+		ExtractSuffix: ".shar",
+	})
+
+	if ver, ok := m.Match("baz-1.0.shar"); !ok || ver != "1.0" {
+		t.Fatal("Non-archive suffix not matched:", ver)
+	}
+
+	if ver, ok := m.Match("baz-1.0.tar.gz"); !ok || ver != "1.0" {
+		t.Fatal("Alternative archive suffix not matched:", ver)
+	}
+}
+
+func TestGenerateGuesses(t *testing.T) {
+	guesses := GenerateGuesses("1.2.3", -1)
+	expected := []string{"2.0.0", "1.3.0", "1.2.4"}
+
+	if len(guesses) != len(expected) {
+		t.Fatal("Incorrect guess count:", guesses)
+	}
+
+	for i, guess := range guesses {
+		if guess != expected[i] {
+			t.Fatal("Incorrect guess:", guess)
+		}
+	}
+
+	guesses = GenerateGuesses("2.4.1", 1)
+
+	if guesses[1] != "2.6.0" {
+		t.Fatal("Incorrect even/odd guess:", guesses[1])
+	}
+}
+
+func TestNewest(t *testing.T) {
+	m := NewMatcher(types.PortInfo{
+		DistName:      "foo-1.2",
+		DistVersion:   "1.2",
+		ExtractSuffix: ".tar.gz",
+	})
+
+	files := make([]*url.URL, 0)
+
+	for _, file := range []string{
+		"http://www.example.net/foo-1.1.tar.gz",
+		"http://www.example.net/foo-1.2.tar.gz",
+		"http://www.example.net/foo-1.10.tar.gz",
+		"http://www.example.net/foo-1.3.tar.gz",
+		"http://www.example.net/bar-9.0.tar.gz",
+		"http://www.example.net/README",
+	} {
+		u, _ := url.Parse(file)
+		files = append(files, u)
+	}
+
+	candidates := m.Candidates(files)
+
+	if len(candidates) != 4 {
+		t.Fatal("Incorrect candidate count:", candidates)
+	}
+
+	best := Newest(candidates, "1.2")
+
+	if best == nil || best.Version != "1.10" {
+		t.Fatal("Incorrect newest candidate:", best)
+	}
+
+	if best.File.String() != "http://www.example.net/foo-1.10.tar.gz" {
+		t.Fatal("Incorrect newest file:", best.File)
+	}
+
+	if Newest(candidates, "1.10") != nil {
+		t.Fatal("Candidate found when up to date")
+	}
+}
+
+func TestCheck(t *testing.T) {
+	cfg := types.PortConfig{
+		LimitVer:     regexp.MustCompile(`^[0-9.]+(rc[0-9]+)?$`),
+		LimitWhich:   1,
+		LimitEven:    true,
+		SkipBeta:     true,
+		SkipVersions: []string{"2.4.1"},
+	}
+
+	cases := []struct {
+		current  string
+		ver      string
+		expected error
+	}{
+		{"2.4.0", "2.4.2", nil},
+		{"2.4.0", "2.6", nil},
+		{"2.4.0", "3", nil},
+		{"2.4.0", "2.5.0", ErrLimitWhich},
+		{"2.4.0", "2.4.1", ErrSkipped},
+		{"2.4.0", "2.4.2-final", ErrLimitVer},
+		{"2.4.0", "2.6rc1", ErrBeta},
+		{"2.6rc1", "2.6rc2", nil},
+	}
+
+	for _, tc := range cases {
+		if err := Check(cfg, tc.current, tc.ver); err != tc.expected {
+			t.Errorf("Check(%q, %q) = %v; expected %v", tc.current, tc.ver, err, tc.expected)
+		}
+	}
+
+	if Check(types.PortConfig{LimitWhich: -1}, "1.0", "1.1beta1") != nil {
+		t.Fatal("Unrestricted config rejected version")
+	}
+}
+
+func TestIsBeta(t *testing.T) {
+	cases := map[string]bool{
+		"1.0":           false,
+		"1.1.1k":        false,
+		"2.0.0":         false,
+		"1.0a1":         true,
+		"1.0b2":         true,
+		"1.0-beta":      true,
+		"1.0.rc1":       true,
+		"1.0RC2":        true,
+		"1.0pre":        true,
+		"2.0-alpha.3":   true,
+		"1.0-dev":       true,
+		"20240101-snap": true,
+		"1.0-nightly":   true,
+	}
+
+	for ver, expected := range cases {
+		if IsBeta(ver) != expected {
+			t.Errorf("IsBeta(%q) = %v; expected %v", ver, !expected, expected)
+		}
+	}
+}
+
+func TestFilter(t *testing.T) {
+	cfg := types.PortConfig{
+		LimitWhich:   -1,
+		SkipBeta:     true,
+		SkipVersions: []string{"1.3"},
+	}
+
+	candidates := []Candidate{
+		{Version: "1.2"},
+		{Version: "1.3"},
+		{Version: "1.4rc1"},
+	}
+
+	accepted, rejected := Filter(cfg, "1.1", candidates)
+
+	if len(accepted) != 1 || accepted[0].Version != "1.2" {
+		t.Fatal("Incorrect accepted candidates:", accepted)
+	}
+
+	if len(rejected) != 2 || rejected[0].Reason != ErrSkipped || rejected[1].Reason != ErrBeta {
+		t.Fatal("Incorrect rejected candidates:", rejected)
+	}
+}
+
+func TestComparePerl(t *testing.T) {
+	tests := []struct {
+		a        string
+		b        string
+		expected int
+	}{
+		{"1.3", "1.23", 1},
+		{"1.23", "1.230", 0},
+		{"1.002003", "v1.2.3", 0},
+		{"v1.2.3", "1.2.3", 0},
+		{"v1.10.0", "v1.9.0", 1},
+		{"1.0203", "v1.20.300", 0},
+		{"1.23_01", "1.23", 1},
+		{"2", "1.999", 1},
+	}
+
+	for _, tc := range tests {
+		if cmp := ComparePerl(tc.a, tc.b); cmp != tc.expected {
+			t.Errorf("ComparePerl(%q, %q) = %d; expected %d", tc.a, tc.b, cmp, tc.expected)
+		}
+	}
+}
+
+func TestPerlVersion(t *testing.T) {
+	tests := []struct {
+		ver      string
+		current  string
+		expected string
+	}{
+		{"1.3", "1.23", "1.30"},
+		{"1.31", "1.3", "1.31"},
+		{"1.24", "1.23", "1.24"},
+		{"v1.2.4", "1.002003", "1.002004"},
+		{"1.002004", "1.2.3", "1.2.4"},
+		{"1.3", "v1.2.3", "v1.300.0"},
+		{"2", "1.5", "2.0"},
+	}
+
+	for _, tc := range tests {
+		ver := PerlVersion(tc.ver, tc.current)
+
+		if ver != tc.expected {
+			t.Errorf("PerlVersion(%q, %q) = %q; expected %q", tc.ver, tc.current, ver, tc.expected)
+		}
+
+		if !IsNewer(ver, tc.current) {
+			t.Errorf("Expected %q to be newer than %q", ver, tc.current)
+		}
+	}
+}